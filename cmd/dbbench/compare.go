@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/timsexperiments/distributed-db-test/internal/test"
+)
+
+const compareUsage = "dbbench compare [-rounds n] [-o file] [-scenario file] [setting flags] <adapter> <adapter>..."
+
+// Compares adapters by running the scenario's phases, or writes and then reads
+// without a scenario file, against them in interleaved rounds. Every adapter
+// runs with the same settings, taking the longest pause, warmup and operation
+// timeout any of them needs, and then the settings of the scenario.
+func compare(ctx context.Context, args []string) error {
+	flags := newFlagSet("compare", compareUsage)
+	rounds := flags.Int("rounds", 10, "the number of interleaved rounds")
+	output := flags.String("o", "", "save the report as JSON to this file")
+	file := flags.String("scenario", "", "take the settings and phases from the scenario in this JSON file")
+	var settings test.Settings
+	addSettingsFlags(flags, &settings)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() < 2 {
+		return fmt.Errorf("Usage: %s", compareUsage)
+	}
+	scenario, err := loadScenario(*file, settings)
+	if err != nil {
+		return err
+	}
+	// The default scenario has sweeps and trials, which cannot be compared, so
+	// without a file the adapters are compared on writes and reads.
+	phases := []string{test.PhaseWrites, test.PhaseReads}
+	if *file != "" {
+		if phases, err = comparePhases(scenario); err != nil {
+			return fmt.Errorf("Invalid scenario %s: %w", *file, err)
+		}
+	}
+
+	adapters := make([]test.Adapter, flags.NArg())
+	for i, name := range flags.Args() {
+		if adapters[i], err = lookupAdapter(name); err != nil {
+			return err
+		}
+		// The results of each contender are told apart by its name.
+		if slices.Contains(flags.Args()[:i], name) {
+			return fmt.Errorf("Adapter %s is listed twice. Compare it with a different adapter, or use -rounds to run it for longer.", name)
+		}
+	}
+
+	runID := test.NewRunID()
+	dbs, err := preflight(ctx, adapters, runID)
+	if err != nil {
+		return err
+	}
+	report := Report{RunID: runID, Scenario: &scenario, Started: time.Now()}
+	contenders := make([]test.Contender, len(adapters))
+	var shared test.Adapter
+	// Tear down and close every adapter, even if a later one fails to set up.
+	defer func() {
+		for i, adapter := range adapters {
+			tester := adapter.Tester(dbs[i])
+			if err := errors.Join(tester.Teardown(context.WithoutCancel(ctx)), tester.Close()); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}
+	}()
+	for i, adapter := range adapters {
+		contenders[i] = test.Contender{Name: adapter.Name, DB: dbs[i]}
+		tester := adapter.Tester(dbs[i])
+		if err := tester.Setup(ctx); err != nil {
+			return err
+		}
+		if err := tester.CheckSchema(ctx); err != nil {
+			return err
+		}
+		report.Adapters = append(report.Adapters, adapter.Name)
+		shared.Pause = max(shared.Pause, adapter.Pause)
+		shared.Warmup = max(shared.Warmup, adapter.Warmup)
+		shared.OpTimeout = max(shared.OpTimeout, adapter.OpTimeout)
+	}
+
+	tester, err := shared.Tester(contenders[0].DB).WithSettings(scenario.Settings)
+	if err != nil {
+		return err
+	}
+	tester = tester.WithRunID(runID)
+	fmt.Printf("Run ID: %s\n", runID)
+	comparisons, err := tester.Compare(ctx, contenders, *rounds, phases...)
+	err = report.add(Phase{Name: "comparison", Comparisons: comparisons}, err)
+	return errors.Join(err, report.save(*output))
+}
+
+// Returns the names of the scenario's phases. Every phase runs with the
+// scenario's settings, so phases that are not one of test.Phases, or that have
+// a name or settings of their own, are an error instead of being compared on
+// something else.
+func comparePhases(scenario Scenario) ([]string, error) {
+	phases := make([]string, len(scenario.Phases))
+	for i, spec := range scenario.Phases {
+		if !slices.Contains(test.Phases(), spec.Phase) {
+			return nil, fmt.Errorf("Phase %d: %s cannot be compared. The phases that can are %s.", i+1, spec.Phase, strings.Join(test.Phases(), ", "))
+		}
+		if spec.Name != "" || !reflect.DeepEqual(scenario.Settings.Override(spec.Settings), scenario.Settings) {
+			return nil, fmt.Errorf("Phase %d: %s has a name or settings of its own. Compare runs every phase with the scenario's settings, so set them on the scenario instead.", i+1, spec.Phase)
+		}
+		phases[i] = spec.Phase
+	}
+	return phases, nil
+}
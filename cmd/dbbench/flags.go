@@ -0,0 +1,112 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/timsexperiments/distributed-db-test/internal/test"
+)
+
+// The prefix of the environment variables that set flags. The flag -op-timeout
+// is set by DBBENCH_OP_TIMEOUT and -mock.multiplier by DBBENCH_MOCK_MULTIPLIER.
+const envPrefix = "DBBENCH_"
+
+// Creates the flag set of a command, with a -help that lists its flags and the
+// environment variables that set them.
+func newFlagSet(name string, usage string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s\n\nFlags:\n", usage)
+		flags.PrintDefaults()
+		fmt.Fprintf(flags.Output(), "\nEvery flag can also be set with an environment variable named after it, for\nexample %s for -op-timeout. Flags win over the environment.\n", envName("op-timeout"))
+	}
+	return flags
+}
+
+// Defines a flag for every tester setting, and the flags of every adapter. The
+// settings only hold the flags that were set, so they can override a scenario.
+func addSettingsFlags(flags *flag.FlagSet, settings *test.Settings) {
+	setting(flags, "total", "the number of operations per phase", strconv.Atoi, func(total int) { settings.Total = total })
+	setting(flags, "duration", "run each phase for this long instead of a total", time.ParseDuration, func(duration time.Duration) { settings.Duration = test.Duration(duration) })
+	setting(flags, "mode", "how operations are scheduled: batch, pool or rate", identity, func(mode string) { settings.Mode = mode })
+	setting(flags, "concurrency", "the wait group size in batch mode or the number of workers in pool mode", strconv.Atoi, func(concurrency int) { settings.Concurrency = concurrency })
+	setting(flags, "rate", "the operations per second in rate mode, which a rate on its own implies", parseFloat, func(rate float64) { settings.Rate = rate })
+	setting(flags, "pause", "the pause between wait groups, replacing the adapter's", time.ParseDuration, func(pause time.Duration) { settings.Pause = pointer(test.Duration(pause)) })
+	setting(flags, "warmup", "the number of warmup operations before each phase", strconv.Atoi, func(warmup int) { settings.Warmup = &warmup })
+	setting(flags, "warmup-duration", "warm up for this long before each phase instead", time.ParseDuration, func(warmup time.Duration) { settings.WarmupDuration = pointer(test.Duration(warmup)) })
+	setting(flags, "op-timeout", "the deadline for each operation, replacing the adapter's", time.ParseDuration, func(timeout time.Duration) { settings.OpTimeout = pointer(test.Duration(timeout)) })
+	setting(flags, "max-error-rate", "the fraction of failed operations (0-1) that aborts a phase", parseFloat, func(rate float64) { settings.MaxErrorRate = &rate })
+	setting(flags, "seed", "the seed for every random choice, to repeat a run exactly", parseInt, func(seed int64) { settings.Seed = &seed })
+	setting(flags, "keys", "the key distribution: sequential, uniform, zipfian:THETA, latest:THETA or hotspot:HOT_KEYS:HOT_OPS", identity, func(keys string) { settings.Keys = keys })
+	boolSetting(flags, "verify", "compare reads with the test data that was written", func(verify bool) { settings.Verify = &verify })
+	setting(flags, "verify-precision", "the precision timestamps are compared at when verifying", time.ParseDuration, func(precision time.Duration) { settings.VerifyPrecision = pointer(test.Duration(precision)) })
+	setting(flags, "page-size", "the number of test data per scan", strconv.Atoi, func(size int) { settings.PageSize = size })
+	setting(flags, "payload", "the payload of written test data: fixed:SIZE, uniform:MIN:MAX or lognormal:MEDIAN:SIGMA, optionally followed by :ascii, :unicode or :random", identity, func(payload string) { settings.Payload = payload })
+	setting(flags, "max-variation", "the coefficient of variation above which trials are flagged as unstable", parseFloat, func(variation float64) { settings.MaxVariation = &variation })
+	setting(flags, "repetitions", "the number of trials to run each phase as", strconv.Atoi, func(repetitions int) { settings.Repetitions = repetitions })
+	boolSetting(flags, "verbose", "log extra info", func(verbose bool) { settings.Verbose = &verbose })
+	for _, adapter := range test.Adapters() {
+		if adapter.Flags != nil {
+			adapter.Flags(flags)
+		}
+	}
+}
+
+// Parses the arguments after setting every flag that has an environment
+// variable, so flags on the command line win.
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	var err error
+	flags.VisitAll(func(f *flag.Flag) {
+		if value, ok := os.LookupEnv(envName(f.Name)); ok && err == nil {
+			if setErr := flags.Set(f.Name, value); setErr != nil {
+				err = fmt.Errorf("Invalid value %q for %s: %w", value, envName(f.Name), setErr)
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return flags.Parse(args)
+}
+
+// Returns the environment variable that sets the flag.
+func envName(flag string) string {
+	return envPrefix + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(flag))
+}
+
+// Defines a flag that calls set with its parsed value when it is set.
+func setting[T any](flags *flag.FlagSet, name string, usage string, parse func(string) (T, error), set func(T)) {
+	flags.Func(name, usage, func(text string) error {
+		value, err := parse(text)
+		if err != nil {
+			return err
+		}
+		set(value)
+		return nil
+	})
+}
+
+// Defines a boolean flag, which can be given without a value, that calls set
+// when it is set.
+func boolSetting(flags *flag.FlagSet, name string, usage string, set func(bool)) {
+	flags.BoolFunc(name, usage, func(text string) error {
+		value, err := strconv.ParseBool(text)
+		if err != nil {
+			return err
+		}
+		set(value)
+		return nil
+	})
+}
+
+func identity(text string) (string, error) { return text, nil }
+
+func parseFloat(text string) (float64, error) { return strconv.ParseFloat(text, 64) }
+
+func parseInt(text string) (int64, error) { return strconv.ParseInt(text, 10, 64) }
+
+func pointer[T any](value T) *T { return &value }
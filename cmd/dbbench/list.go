@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/timsexperiments/distributed-db-test/internal/test"
+)
+
+// Lists the registered adapters with the environment variables they read, and
+// the phases that can be compared.
+func list() error {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "ADAPTER\tDESCRIPTION\tENVIRONMENT")
+	for _, adapter := range test.Adapters() {
+		fmt.Fprintf(writer, "%s\t%s\t%s\n", adapter.Name, adapter.Description, strings.Join(adapter.Env, ", "))
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\nPhases: %s\n", strings.Join(test.Phases(), ", "))
+	fmt.Printf("Scenario phases: %s, %s, %s\n", phaseMixed, phaseBatchSweep, phasePayloadSweep)
+	return nil
+}
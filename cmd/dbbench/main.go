@@ -0,0 +1,89 @@
+// Command dbbench benchmarks distributed databases. Each backend is an adapter
+// that registers itself with the test package, so adding a backend only needs
+// an import here.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+
+	"github.com/joho/godotenv"
+	_ "github.com/timsexperiments/distributed-db-test/internal/mock"
+	_ "github.com/timsexperiments/distributed-db-test/internal/planetscale"
+	"github.com/timsexperiments/distributed-db-test/internal/test"
+	_ "github.com/timsexperiments/distributed-db-test/internal/turso"
+	_ "github.com/timsexperiments/distributed-db-test/internal/upstash"
+)
+
+const usage = `Usage: dbbench <command> [arguments]
+
+Commands:
+  run [flags] [adapter...]      Run a scenario's phases against each adapter and
+                                compare them in one table.
+  compare [flags] <adapter>...  Compare adapters in interleaved rounds.
+  list                          List the adapters and phases.
+  report <file>                 Print a report saved with -o.
+
+Run dbbench <command> -help to see a command's flags. Every tester setting and
+adapter option is a flag, and can also be set with a DBBENCH_ environment
+variable.
+
+Scenarios are JSON files that describe the settings and phases of a run. See
+cmd/dbbench/scenarios for examples; default.json runs when no file is given.
+`
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	// Stop catching interrupts after the first one, so a second Ctrl-C kills a
+	// teardown or close that hangs.
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	var err error
+	switch command, args := os.Args[1], os.Args[2:]; command {
+	case "run":
+		err = run(ctx, args)
+	case "compare":
+		err = compare(ctx, args)
+	case "list":
+		err = list()
+	case "report":
+		err = report(args)
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q.\n\n%s", command, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// Looks up the adapter, loading the .env file first if it reads environment
+// variables. Without a .env file the variables can come from the environment,
+// and the adapter reports any that are missing when it is opened.
+func lookupAdapter(name string) (test.Adapter, error) {
+	adapter, ok := test.LookupAdapter(name)
+	if !ok {
+		return adapter, fmt.Errorf("Unknown adapter %q. Run dbbench list to see the adapters.", name)
+	}
+	if len(adapter.Env) > 0 {
+		if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return adapter, fmt.Errorf("Unable to read the .env file: %w", err)
+		}
+	}
+	return adapter, nil
+}
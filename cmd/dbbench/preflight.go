@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/timsexperiments/distributed-db-test/internal/test"
+)
+
+// Opens every adapter and runs its preflight checks before anything is set up
+// or timed. Every adapter is checked even if one fails, so the error lists
+// everything there is to fix. If any check fails the test databases that were
+// opened are closed again.
+func preflight(ctx context.Context, adapters []test.Adapter, runID string) ([]test.TestDatabase, error) {
+	dbs := make([]test.TestDatabase, 0, len(adapters))
+	var errs []error
+	for _, adapter := range adapters {
+		db, err := adapter.Open(ctx, runID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Unable to open %s: %w", adapter.Name, err))
+			continue
+		}
+		dbs = append(dbs, db)
+		if err := adapter.Tester(db).Preflight(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", adapter.Name, err))
+			continue
+		}
+		fmt.Printf("Preflight passed for %s.\n", adapter.Name)
+	}
+	if len(errs) == 0 {
+		return dbs, nil
+	}
+	for _, db := range dbs {
+		errs = append(errs, test.NewDbTester(db).Close())
+	}
+	return nil, errors.Join(errs...)
+}
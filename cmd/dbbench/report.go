@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/timsexperiments/distributed-db-test/internal/test"
+)
+
+// Everything a command measured, as saved with -o and printed by the report
+// command.
+type Report struct {
+	RunID    string    // The ID the run's tables or keys were namespaced with.
+	Scenario *Scenario `json:",omitempty"` // The scenario that was run, so the run can be repeated.
+	Adapters []string  // The adapters that were benchmarked.
+	Started  time.Time // When the run started.
+	Phases   []Phase   // The phases in the order they ran.
+}
+
+// The outcome of one phase. Only the field for the kind of phase is set.
+type Phase struct {
+	Name          string              // The phase, for example "writes".
+	Adapter       string              `json:",omitempty"` // The adapter the phase ran against.
+	Skipped       string              `json:",omitempty"` // Why the phase was skipped, if the adapter does not support it.
+	Error         string              `json:",omitempty"` // Why the phase failed, if it did.
+	Result        *test.Result        `json:",omitempty"`
+	Trials        *test.Trials        `json:",omitempty"`
+	BatchSweeps   []test.BatchSweep   `json:",omitempty"`
+	PayloadSweeps []test.PayloadSweep `json:",omitempty"`
+	Comparisons   []test.Comparison   `json:",omitempty"`
+}
+
+// Adds the phase to the report and prints it. Returns the phase's error,
+// unless the adapter does not support the phase, in which case it is skipped.
+func (report *Report) add(phase Phase, err error) error {
+	if errors.Is(err, test.ErrUnsupported) {
+		phase.Skipped, err = err.Error(), nil
+	} else if err != nil {
+		phase.Error = err.Error()
+	}
+	report.Phases = append(report.Phases, phase)
+	phase.print(os.Stdout)
+	if err != nil {
+		return fmt.Errorf("Unable to finish %s: %w", phase.title(), err)
+	}
+	return nil
+}
+
+// Saves the report as JSON, if there is a file to save it to.
+func (report Report) save(file string) error {
+	if file == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		return fmt.Errorf("Unable to save the report: %w", err)
+	}
+	fmt.Printf("Saved the report to %s.\n", file)
+	return nil
+}
+
+// Names the phase and the adapter it ran against.
+func (phase Phase) title() string {
+	if phase.Adapter == "" {
+		return phase.Name
+	}
+	return phase.Name + " on " + phase.Adapter
+}
+
+func (phase Phase) print(writer io.Writer) {
+	name := phase.title()
+	switch {
+	case phase.Skipped != "":
+		fmt.Fprintf(writer, "Skipping %s: %s\n", name, phase.Skipped)
+	case phase.Result != nil:
+		result := phase.Result
+		fmt.Fprintf(writer, "Finished %s: %d operations in %s. Average time was %s.\n", name, result.Count, result.Elapsed, result.Mean)
+		fmt.Fprintln(writer, result)
+	case phase.Trials != nil:
+		fmt.Fprintf(writer, "Finished %s: %d operations in total.\n", name, phase.Trials.Merged.Count)
+		fmt.Fprintln(writer, phase.Trials)
+	case phase.BatchSweeps != nil:
+		fmt.Fprintf(writer, "Finished %s:\n", name)
+		for _, sweep := range phase.BatchSweeps {
+			fmt.Fprintf(writer, "  %s\n", sweep)
+		}
+	case phase.PayloadSweeps != nil:
+		fmt.Fprintf(writer, "Finished %s:\n", name)
+		for _, sweep := range phase.PayloadSweeps {
+			fmt.Fprintf(writer, "  %s\n", sweep)
+		}
+	case phase.Comparisons != nil:
+		for _, comparison := range phase.Comparisons {
+			fmt.Fprintln(writer, comparison)
+		}
+	}
+}
+
+// Prints a report saved with -o.
+func report(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("Usage: dbbench report <file>")
+	}
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	var saved Report
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("Unable to read the report in %s: %w", args[0], err)
+	}
+	fmt.Printf("Run ID: %s\nAdapters: %v\nStarted: %s\n", saved.RunID, saved.Adapters, saved.Started.Format(time.RFC1123))
+	if saved.Scenario != nil && saved.Scenario.Name != "" {
+		fmt.Printf("Scenario: %s\n", saved.Scenario.Name)
+	}
+	for _, phase := range saved.Phases {
+		phase.print(os.Stdout)
+		if phase.Error != "" {
+			fmt.Printf("Failed %s: %s\n", phase.title(), phase.Error)
+		}
+	}
+	if len(saved.Adapters) > 1 {
+		fmt.Println()
+		saved.summary(os.Stdout)
+	}
+	return nil
+}
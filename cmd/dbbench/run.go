@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/timsexperiments/distributed-db-test/internal/test"
+)
+
+const runUsage = "dbbench run [-o file] [-scenario file] [-parallel] [setting flags] [adapter...]"
+
+// Runs a scenario's phases against each adapter in turn, or all at once with
+// -parallel, setting each one up before and tearing it down after. The
+// adapters on the command line take the place of the scenario's, and the
+// setting flags override its settings. Every adapter runs with the same seed.
+// With more than one adapter the run ends with a table comparing them.
+func run(ctx context.Context, args []string) error {
+	flags := newFlagSet("run", runUsage)
+	output := flags.String("o", "", "save the report as JSON to this file")
+	file := flags.String("scenario", "", "run the scenario in this JSON file instead of the default one")
+	parallel := flags.Bool("parallel", false, "run the adapters at the same time instead of one after another")
+	var settings test.Settings
+	addSettingsFlags(flags, &settings)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	scenario, err := loadScenario(*file, settings)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		scenario.Adapters = flags.Args()
+	}
+	if len(scenario.Adapters) == 0 {
+		return fmt.Errorf("Usage: %s", runUsage)
+	}
+	// Every adapter gets the same seed, so they all pick the same keys and the
+	// summary compares like with like. The report records it for reruns.
+	if scenario.Seed == nil {
+		seed := time.Now().UnixNano()
+		scenario.Seed = &seed
+	}
+
+	adapters := make([]test.Adapter, len(scenario.Adapters))
+	for i, name := range scenario.Adapters {
+		if adapters[i], err = lookupAdapter(name); err != nil {
+			return err
+		}
+		// The phases of each adapter are told apart by its name.
+		if slices.Contains(scenario.Adapters[:i], name) {
+			return fmt.Errorf("Adapter %s is listed twice. Use -repetitions to run its phases more than once.", name)
+		}
+	}
+
+	runID := test.NewRunID()
+	dbs, err := preflight(ctx, adapters, runID)
+	if err != nil {
+		return err
+	}
+	report := Report{RunID: runID, Scenario: &scenario, Adapters: scenario.Adapters, Started: time.Now()}
+	var mutex sync.Mutex
+	add := func(phase Phase, err error) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return report.add(phase, err)
+	}
+	fmt.Printf("Run ID: %s\n", runID)
+	errs := make([]error, len(adapters))
+	if *parallel {
+		var group sync.WaitGroup
+		for i, adapter := range adapters {
+			group.Add(1)
+			go func(i int, adapter test.Adapter) {
+				defer group.Done()
+				errs[i] = runAdapter(ctx, adapter, dbs[i], scenario, runID, add)
+			}(i, adapter)
+		}
+		group.Wait()
+	} else {
+		// A failing adapter does not stop the rest, so one bad backend does not
+		// cost the results of the others.
+		for i, adapter := range adapters {
+			errs[i] = runAdapter(ctx, adapter, dbs[i], scenario, runID, add)
+		}
+	}
+	if len(adapters) > 1 {
+		fmt.Println()
+		report.summary(os.Stdout)
+	}
+	return errors.Join(append(errs, report.save(*output))...)
+}
+
+// Runs the scenario's phases against the adapter's test database, adding each
+// phase to the report with add.
+func runAdapter(ctx context.Context, adapter test.Adapter, db test.TestDatabase, scenario Scenario, runID string, add func(Phase, error) error) error {
+	tester := adapter.Tester(db).WithRunID(runID)
+	return tester.Run(ctx, func(ctx context.Context) error {
+		for _, spec := range scenario.Phases {
+			settings := scenario.Settings.Override(spec.Settings)
+			phaseTester, err := tester.WithSettings(settings)
+			if err != nil {
+				return err
+			}
+			phase, err := spec.run(ctx, phaseTester, settings.Repetitions)
+			phase.Adapter = adapter.Name
+			if err := add(phase, err); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/timsexperiments/distributed-db-test/internal/test"
+)
+
+// The names of the phases that only scenarios can run, on top of test.Phases.
+const (
+	phaseMixed        = "mixed"         // A custom workload mix.
+	phaseBatchSweep   = "batch-sweep"   // Batch writes and reads at several batch sizes.
+	phasePayloadSweep = "payload-sweep" // Writes and reads at several payload sizes.
+)
+
+// The scenario run when no scenario file is given.
+//
+//go:embed scenarios/default.json
+var defaultScenario []byte
+
+// A benchmark as it is described in a scenario file: which adapters to run,
+// the settings every phase starts from and the phases in the order they run.
+type Scenario struct {
+	Name     string      `json:"name,omitempty"`     // The name shown in reports.
+	Adapters []string    `json:"adapters,omitempty"` // The adapters to run when none are given on the command line.
+	Phases   []PhaseSpec `json:"phases"`             // The phases in the order they run.
+	test.Settings
+}
+
+// One phase of a scenario. Its settings override the scenario's for this phase
+// only. In a scenario file a phase is either its name or an object.
+type PhaseSpec struct {
+	Phase          string        `json:"phase"`                    // The phase, one of test.Phases, "mixed", "batch-sweep" or "payload-sweep".
+	Name           string        `json:"name,omitempty"`           // The name shown in reports. Defaults to the phase.
+	Workload       *WorkloadSpec `json:"workload,omitempty"`       // The workload of a mixed phase.
+	BatchSizes     []int         `json:"batchSizes,omitempty"`     // The batch sizes of a batch sweep.
+	PayloadSizes   []int         `json:"payloadSizes,omitempty"`   // The payload sizes of a payload sweep, in bytes.
+	PayloadContent string        `json:"payloadContent,omitempty"` // The payload content of a payload sweep. Defaults to ASCII.
+	test.Settings
+}
+
+// A custom workload mix, as it is written in scenario files.
+type WorkloadSpec struct {
+	Read            float64 `json:"read,omitempty"`
+	Update          float64 `json:"update,omitempty"`
+	Insert          float64 `json:"insert,omitempty"`
+	Scan            float64 `json:"scan,omitempty"`
+	ReadModifyWrite float64 `json:"readModifyWrite,omitempty"`
+	Keys            string  `json:"keys,omitempty"` // The key distribution, as read by test.ParseKeyDistribution.
+}
+
+func (spec *PhaseSpec) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &spec.Phase); err == nil {
+		return nil
+	}
+	// The alias drops this method so the object is read field by field.
+	type phaseSpec PhaseSpec
+	return json.Unmarshal(data, (*phaseSpec)(spec))
+}
+
+// Reads the scenario file, or the default scenario if there is no file, with
+// the settings overriding the file's.
+func loadScenario(file string, settings test.Settings) (Scenario, error) {
+	data := defaultScenario
+	if file != "" {
+		var err error
+		if data, err = os.ReadFile(file); err != nil {
+			return Scenario{}, err
+		}
+	}
+	var scenario Scenario
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&scenario); err != nil {
+		return scenario, fmt.Errorf("Unable to read the scenario %s: %w", scenarioFile(file), err)
+	}
+	scenario = scenario.override(settings)
+	if err := scenario.validate(); err != nil {
+		return scenario, fmt.Errorf("Invalid scenario %s: %w", scenarioFile(file), err)
+	}
+	return scenario, nil
+}
+
+// Names the scenario file in errors.
+func scenarioFile(file string) string {
+	if file == "" {
+		return "(default)"
+	}
+	return file
+}
+
+// Checks every phase and setting before anything runs, so a typo does not
+// surface halfway through a long run.
+func (scenario Scenario) validate() error {
+	if len(scenario.Phases) == 0 {
+		return fmt.Errorf("There are no phases.")
+	}
+	for _, name := range scenario.Adapters {
+		if _, ok := test.LookupAdapter(name); !ok {
+			return fmt.Errorf("Unknown adapter %q.", name)
+		}
+	}
+	if _, err := test.NewDbTester(nil).WithSettings(scenario.Settings); err != nil {
+		return err
+	}
+	known := append(test.Phases(), phaseMixed, phaseBatchSweep, phasePayloadSweep)
+	for i, spec := range scenario.Phases {
+		if !slices.Contains(known, spec.Phase) {
+			return fmt.Errorf("Phase %d: unknown phase %q. The phases are %s.", i+1, spec.Phase, strings.Join(known, ", "))
+		}
+		if _, err := test.NewDbTester(nil).WithSettings(scenario.Settings.Override(spec.Settings)); err != nil {
+			return fmt.Errorf("Phase %d: %w", i+1, err)
+		}
+		switch spec.Phase {
+		case phaseMixed:
+			if _, err := spec.workload(); err != nil {
+				return fmt.Errorf("Phase %d: %w", i+1, err)
+			}
+		case phaseBatchSweep:
+			if len(spec.BatchSizes) == 0 {
+				return fmt.Errorf("Phase %d: a batch sweep needs batchSizes.", i+1)
+			}
+			if size := slices.Min(spec.BatchSizes); size < 1 {
+				return fmt.Errorf("Phase %d: batch size %d is too small. Every batch needs at least 1 test data.", i+1, size)
+			}
+		case phasePayloadSweep:
+			if len(spec.PayloadSizes) == 0 {
+				return fmt.Errorf("Phase %d: a payload sweep needs payloadSizes.", i+1)
+			}
+			if size := slices.Min(spec.PayloadSizes); size < 1 {
+				return fmt.Errorf("Phase %d: payload size %d is too small. Every text needs at least 1 byte.", i+1, size)
+			}
+			if _, err := spec.payloadContent(); err != nil {
+				return fmt.Errorf("Phase %d: %w", i+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the scenario with the settings, usually from the command line,
+// overriding the scenario's and every phase's own.
+func (scenario Scenario) override(settings test.Settings) Scenario {
+	scenario.Settings = scenario.Settings.Override(settings)
+	phases := make([]PhaseSpec, len(scenario.Phases))
+	for i, spec := range scenario.Phases {
+		spec.Settings = spec.Settings.Override(settings)
+		phases[i] = spec
+	}
+	scenario.Phases = phases
+	return scenario
+}
+
+// Returns the workload of a mixed phase.
+func (spec PhaseSpec) workload() (test.Workload, error) {
+	if spec.Workload == nil {
+		return test.Workload{}, fmt.Errorf("A mixed phase needs a workload.")
+	}
+	workload := test.Workload{
+		Name:            spec.name(),
+		Read:            spec.Workload.Read,
+		Update:          spec.Workload.Update,
+		Insert:          spec.Workload.Insert,
+		Scan:            spec.Workload.Scan,
+		ReadModifyWrite: spec.Workload.ReadModifyWrite,
+	}
+	if workload.Read+workload.Update+workload.Insert+workload.Scan+workload.ReadModifyWrite <= 0 {
+		return workload, fmt.Errorf("The workload has no operations.")
+	}
+	if spec.Workload.Keys != "" {
+		keys, err := test.ParseKeyDistribution(spec.Workload.Keys)
+		if err != nil {
+			return workload, err
+		}
+		workload.Keys = keys
+	}
+	return workload, nil
+}
+
+// Returns the payload content of a payload sweep.
+func (spec PhaseSpec) payloadContent() (test.PayloadContent, error) {
+	if spec.PayloadContent == "" {
+		return test.ASCIIContent, nil
+	}
+	content := test.PayloadContent(spec.PayloadContent)
+	switch content {
+	case test.ASCIIContent, test.UnicodeContent, test.RandomContent:
+		return content, nil
+	}
+	return content, fmt.Errorf("Unknown payload content %q. Use %s, %s or %s.", spec.PayloadContent, test.ASCIIContent, test.UnicodeContent, test.RandomContent)
+}
+
+// Returns the name shown in reports.
+func (spec PhaseSpec) name() string {
+	if spec.Name != "" {
+		return spec.Name
+	}
+	return spec.Phase
+}
+
+// The tester methods a scenario phase needs, since the tester's type is
+// internal to the test package.
+type phaseTester interface {
+	RunPhase(ctx context.Context, phase string) (test.Result, error)
+	TimeMixed(ctx context.Context, workload test.Workload) (test.Result, error)
+	Trials(ctx context.Context, count int, phase func(ctx context.Context) (test.Result, error)) (test.Trials, error)
+	SweepBatchSizes(ctx context.Context, sizes []int) ([]test.BatchSweep, error)
+	SweepPayloadSizes(ctx context.Context, sizes []int, content test.PayloadContent) ([]test.PayloadSweep, error)
+}
+
+// Runs the phase with a tester that already has the phase's settings. With
+// more than one repetition the phase is run as trials.
+func (spec PhaseSpec) run(ctx context.Context, tester phaseTester, repetitions int) (Phase, error) {
+	phase := Phase{Name: spec.name()}
+	switch spec.Phase {
+	case phaseBatchSweep:
+		sweeps, err := tester.SweepBatchSizes(ctx, spec.BatchSizes)
+		phase.BatchSweeps = sweeps
+		return phase, err
+	case phasePayloadSweep:
+		content, err := spec.payloadContent()
+		if err != nil {
+			return phase, err
+		}
+		sweeps, err := tester.SweepPayloadSizes(ctx, spec.PayloadSizes, content)
+		phase.PayloadSweeps = sweeps
+		return phase, err
+	}
+	timed := func(ctx context.Context) (test.Result, error) {
+		if spec.Phase == phaseMixed {
+			workload, err := spec.workload()
+			if err != nil {
+				return test.Result{}, err
+			}
+			return tester.TimeMixed(ctx, workload)
+		}
+		return tester.RunPhase(ctx, spec.Phase)
+	}
+	if repetitions > 1 {
+		trials, err := tester.Trials(ctx, repetitions, timed)
+		phase.Trials = &trials
+		return phase, err
+	}
+	result, err := timed(ctx)
+	phase.Result = &result
+	return phase, err
+}
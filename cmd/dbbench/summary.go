@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/timsexperiments/distributed-db-test/internal/test"
+)
+
+// Prints one table comparing the adapters' throughput and latency percentiles
+// in every timed phase, with the phases in the order they ran. Throughput
+// leaves out the pauses between wait groups, so adapters that pause can be
+// compared with ones that do not. A phase that
+// runs more than once, like reads before and after updates, gets a row for
+// each run. Trials show their merged result. Sweeps and comparisons have
+// tables of their own and are left out.
+func (report Report) summary(writer io.Writer) {
+	// Each adapter runs the same phases in the same order, so the n-th phase of
+	// a name is the same phase for every adapter, even when the adapters ran at
+	// the same time.
+	type row struct {
+		name string
+		run  int // How many phases of the name ran before this one.
+	}
+	var rows []row
+	results := make(map[row]map[string]test.Result)
+	runs := make(map[string]map[string]int)
+	for _, phase := range report.Phases {
+		if runs[phase.Adapter] == nil {
+			runs[phase.Adapter] = make(map[string]int)
+		}
+		key := row{name: phase.Name, run: runs[phase.Adapter][phase.Name]}
+		runs[phase.Adapter][phase.Name]++
+		var result test.Result
+		switch {
+		case phase.Result != nil:
+			result = *phase.Result
+		case phase.Trials != nil:
+			result = phase.Trials.Merged
+		default:
+			continue
+		}
+		if !slices.Contains(rows, key) {
+			rows = append(rows, key)
+			results[key] = make(map[string]test.Result)
+		}
+		results[key][phase.Adapter] = result
+	}
+	if len(rows) == 0 {
+		return
+	}
+
+	table := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "PHASE\tADAPTER\tACTIVE OPS/S\tP50\tP90\tP99\tP99.9\tERRORS")
+	for _, key := range rows {
+		name := key.name
+		if key.run > 0 {
+			name = fmt.Sprintf("%s #%d", name, key.run+1)
+		}
+		for _, adapter := range report.Adapters {
+			result, ok := results[key][adapter]
+			if !ok {
+				fmt.Fprintf(table, "%s\t%s\t-\t-\t-\t-\t-\t-\n", name, adapter)
+				continue
+			}
+			fmt.Fprintf(table, "%s\t%s\t%.1f\t%s\t%s\t%s\t%s\t%.2f%%\n", name, adapter, result.ActiveThroughput(),
+				round(result.P50), round(result.P90), round(result.P99), round(result.P999), result.ErrorRate()*100)
+		}
+	}
+	table.Flush()
+}
+
+// Rounds the latency to three significant figures, so the table stays narrow.
+func round(latency time.Duration) time.Duration {
+	unit := time.Nanosecond
+	for limit := 1000 * time.Nanosecond; latency >= limit; limit *= 10 {
+		unit *= 10
+	}
+	return latency.Round(unit)
+}
@@ -1,6 +1,11 @@
 package mock
 
 import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/timsexperiments/distributed-db-test/internal/test"
@@ -9,14 +14,160 @@ import (
 // MockDatabase for demonstration purposes
 type MockDatabase struct {
 	Multiplier int
+	data       *sync.Map // The written test data by key. Nil if the mock does not store anything.
+	counters   *sync.Map // The counters by key, as *int64.
 }
 
-func (db MockDatabase) ReadTestData(int64) (*test.TestData, error) {
-	time.Sleep(time.Duration(db.Multiplier) * time.Microsecond)
-	return &test.TestData{}, nil
+// Creates a mock database that sleeps for multiplier microseconds per request
+// and stores what is written, so reads can be verified.
+func NewMockDatabase(multiplier int) MockDatabase {
+	return MockDatabase{Multiplier: multiplier, data: &sync.Map{}, counters: &sync.Map{}}
 }
 
-func (db MockDatabase) WriteTestData(test.TestData) error {
-	time.Sleep(time.Duration(db.Multiplier) * time.Microsecond)
+func (db MockDatabase) ReadTestData(key int64) (*test.TestData, error) {
+	return db.ReadTestDataContext(context.Background(), key)
+}
+
+func (db MockDatabase) ReadTestDataContext(ctx context.Context, key int64) (*test.TestData, error) {
+	if err := db.wait(ctx); err != nil {
+		return nil, err
+	}
+	if db.data == nil {
+		return &test.TestData{}, nil
+	}
+	data, ok := db.data.Load(key)
+	if !ok {
+		return nil, test.ErrNotFound
+	}
+	result := data.(test.TestData)
+	return &result, nil
+}
+
+func (db MockDatabase) ScanTestData(ctx context.Context, from int64, to int64, limit int) ([]test.TestData, error) {
+	if err := db.wait(ctx); err != nil {
+		return nil, err
+	}
+	page := make([]test.TestData, 0)
+	if db.data == nil {
+		return page, nil
+	}
+	db.data.Range(func(key, data any) bool {
+		if key := key.(int64); key >= from && key <= to {
+			page = append(page, data.(test.TestData))
+		}
+		return true
+	})
+	sort.Slice(page, func(i, j int) bool { return page[i].Key < page[j].Key })
+	if len(page) > limit {
+		page = page[:limit]
+	}
+	return page, nil
+}
+
+func (db MockDatabase) WriteTestData(data test.TestData) error {
+	return db.WriteTestDataContext(context.Background(), data)
+}
+
+func (db MockDatabase) WriteTestDataContext(ctx context.Context, data test.TestData) error {
+	if err := db.wait(ctx); err != nil {
+		return err
+	}
+	db.store(data)
+	return nil
+}
+
+func (db MockDatabase) WriteTestDataBatch(ctx context.Context, batch []test.TestData) error {
+	if err := db.wait(ctx); err != nil {
+		return err
+	}
+	for _, data := range batch {
+		db.store(data)
+	}
+	return nil
+}
+
+func (db MockDatabase) ReadTestDataBatch(ctx context.Context, keys []int64) ([]test.TestData, error) {
+	if err := db.wait(ctx); err != nil {
+		return nil, err
+	}
+	batch := make([]test.TestData, 0, len(keys))
+	for _, key := range keys {
+		if db.data == nil {
+			batch = append(batch, test.TestData{Key: key})
+		} else if data, ok := db.data.Load(key); ok {
+			batch = append(batch, data.(test.TestData))
+		}
+	}
+	return batch, nil
+}
+
+func (db MockDatabase) UpdateTestData(ctx context.Context, data test.TestData) error {
+	if err := db.wait(ctx); err != nil {
+		return err
+	}
+	db.store(data)
 	return nil
 }
+
+func (db MockDatabase) DeleteTestData(ctx context.Context, key int64) error {
+	if err := db.wait(ctx); err != nil {
+		return err
+	}
+	if db.data != nil {
+		db.data.Delete(key)
+	}
+	return nil
+}
+
+func (db MockDatabase) ResetCounter(ctx context.Context, key int64) error {
+	if db.counters == nil {
+		return fmt.Errorf("Counters need a mock created with NewMockDatabase: %w", test.ErrUnsupported)
+	}
+	if err := db.wait(ctx); err != nil {
+		return err
+	}
+	db.counters.Store(key, new(int64))
+	return nil
+}
+
+func (db MockDatabase) IncrementCounter(ctx context.Context, key int64) error {
+	if err := db.wait(ctx); err != nil {
+		return err
+	}
+	counter, ok := db.counters.Load(key)
+	if !ok {
+		return test.ErrNotFound
+	}
+	atomic.AddInt64(counter.(*int64), 1)
+	return nil
+}
+
+func (db MockDatabase) ReadCounter(ctx context.Context, key int64) (int64, error) {
+	if err := db.wait(ctx); err != nil {
+		return 0, err
+	}
+	counter, ok := db.counters.Load(key)
+	if !ok {
+		return 0, test.ErrNotFound
+	}
+	return atomic.LoadInt64(counter.(*int64)), nil
+}
+
+// Keeps the test data if the mock stores what is written.
+func (db MockDatabase) store(data test.TestData) {
+	if db.data != nil {
+		db.data.Store(data.Key, data)
+	}
+}
+
+// Simulates the latency of a request, returning early if the context is canceled.
+func (db MockDatabase) wait(ctx context.Context) error {
+	timer := time.NewTimer(time.Duration(db.Multiplier) * time.Microsecond)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,28 @@
+package mock
+
+import (
+	"context"
+	"flag"
+
+	"github.com/timsexperiments/distributed-db-test/internal/test"
+)
+
+// The simulated latency of each request in microseconds.
+const multiplierDefault = 10
+
+// The multiplier the registered adapter opens mock databases with, set by the
+// mock.multiplier flag.
+var multiplier = multiplierDefault
+
+func init() {
+	test.Register(test.Adapter{
+		Name:        "mock",
+		Description: "An in-memory mock that sleeps for a fixed time per request",
+		Open: func(context.Context, string) (test.TestDatabase, error) {
+			return NewMockDatabase(multiplier), nil
+		},
+		Flags: func(flags *flag.FlagSet) {
+			flags.IntVar(&multiplier, "mock.multiplier", multiplierDefault, "the simulated latency of each mock request in microseconds")
+		},
+	})
+}
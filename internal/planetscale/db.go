@@ -1,6 +1,7 @@
 package planetscale
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -43,20 +44,93 @@ type QueryResponse struct {
 		} `json:"rows"`
 	} `json:"result"`
 	Timing float64 `json:"timing"`
+	Error  *struct {
+		Message string `json:"message"`
+		Code    string `json:"code"`
+	} `json:"error"`
 }
 
+// The layout of DATETIME values in queries and responses. The timestamp column
+// is DATETIME(6), so the microseconds are stored rather than rounded away.
+// Timestamps are written in UTC, since they are parsed back as UTC.
+const timestampLayout = "2006-01-02 15:04:05.999999"
+
 type PlanetScale struct {
-	auth string
-	url  string
+	auth  string
+	url   string
+	runID string // Suffixes the table names so runs do not share tables. Empty for the unsuffixed tables.
 }
 
 func NewPlanetScaleCleint(connectionUrl, auth string) PlanetScale {
 	return PlanetScale{auth: auth, url: connectionUrl}
 }
 
+// Suffixes the MySQL table names with the run ID, so runs on the same
+// PlanetScale branch do not share tables.
+func (db PlanetScale) WithRunID(runID string) PlanetScale {
+	db.runID = runID
+	return db
+}
+
+// The MySQL table holding this run's test data.
+func (db PlanetScale) testdata() string {
+	return test.TableName("testdata", db.runID)
+}
+
+// The MySQL table holding this run's counters.
+func (db PlanetScale) counters() string {
+	return test.TableName("counters", db.runID)
+}
+
+// Runs a query that touches no table, to check the query API is reachable and
+// accepts the credentials.
+func (db PlanetScale) Preflight(ctx context.Context) error {
+	_, err := db.ExecContext(ctx, "SELECT 1")
+	switch {
+	case test.IsAuthError(err):
+		return fmt.Errorf("PlanetScale rejected PLANETSCALE_AUTH. It should be the base64 encoding of <username>:<password> of a current password: %w", err)
+	case err != nil:
+		return fmt.Errorf("Unable to query PlanetScale. Check PLANETSCALE_DB_URL points at your database's query endpoint: %w", err)
+	}
+	return nil
+}
+
+// Drops and recreates the run's tables through the query API, so no rows from
+// an earlier run with the same ID survive.
+func (db PlanetScale) Setup(ctx context.Context) error {
+	for _, statement := range []string{
+		fmt.Sprintf("DROP TABLE IF EXISTS %s", db.testdata()),
+		fmt.Sprintf("CREATE TABLE %s (id INT PRIMARY KEY, text MEDIUMTEXT, timestamp DATETIME(6))", db.testdata()),
+		fmt.Sprintf("DROP TABLE IF EXISTS %s", db.counters()),
+		fmt.Sprintf("CREATE TABLE %s (id INT PRIMARY KEY, value BIGINT NOT NULL)", db.counters()),
+	} {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("%s: %w", statement, err)
+		}
+	}
+	return nil
+}
+
+// Drops the run's tables through the query API.
+func (db PlanetScale) Teardown(ctx context.Context) error {
+	for _, statement := range []string{
+		fmt.Sprintf("DROP TABLE IF EXISTS %s", db.testdata()),
+		fmt.Sprintf("DROP TABLE IF EXISTS %s", db.counters()),
+	} {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("%s: %w", statement, err)
+		}
+	}
+	return nil
+}
+
 func (db PlanetScale) ReadTestData(id int64) (*test.TestData, error) {
-	query := fmt.Sprintf("SELECT id, text, timestamp FROM testdata WHERE id = %d;", id)
-	response, err := db.Exec(query)
+	return db.ReadTestDataContext(context.Background(), id)
+}
+
+func (db PlanetScale) ReadTestDataContext(ctx context.Context, id int64) (*test.TestData, error) {
+	query := fmt.Sprintf("SELECT id, text, timestamp FROM %s WHERE id = %d;", db.testdata(), id)
+	response, err := db.ExecContext(ctx, query)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		return nil, err
@@ -66,12 +140,98 @@ func (db PlanetScale) ReadTestData(id int64) (*test.TestData, error) {
 		fmt.Fprintf(os.Stderr, "Unable to extract test data from response [%v]: %s\n", response, err)
 		return nil, err
 	}
+	if len(data) == 0 {
+		return nil, test.ErrNotFound
+	}
 	return &data[0], nil
 }
 
+func (db PlanetScale) ScanTestData(ctx context.Context, from int64, to int64, limit int) ([]test.TestData, error) {
+	query := fmt.Sprintf("SELECT id, text, timestamp FROM %s WHERE id BETWEEN %d AND %d ORDER BY id LIMIT %d;", db.testdata(), from, to, limit)
+	response, err := db.ExecContext(ctx, query)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return nil, err
+	}
+	data, err := extractTestData(*response)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to extract test data from response [%v]: %s\n", response, err)
+		return nil, err
+	}
+	return data, nil
+}
+
 func (db PlanetScale) WriteTestData(data test.TestData) error {
-	insertQuery := fmt.Sprintf("INSERT INTO testdata (id, text, timestamp) VALUES (%d, '%s', '%s')", data.Key, data.Text, data.Timestamp.Format("2006-01-02 15:04:05.999999"))
-	_, err := db.Exec(insertQuery)
+	return db.WriteTestDataContext(context.Background(), data)
+}
+
+func (db PlanetScale) WriteTestDataContext(ctx context.Context, data test.TestData) error {
+	insertQuery := fmt.Sprintf("INSERT INTO %s (id, text, timestamp) VALUES (%d, %s, '%s')", db.testdata(), data.Key, quote(data.Text), data.Timestamp.UTC().Format(timestampLayout))
+	_, err := db.ExecContext(ctx, insertQuery)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		return err
+	}
+
+	return nil
+}
+
+func (db PlanetScale) WriteTestDataBatch(ctx context.Context, batch []test.TestData) error {
+	// MySQL rejects INSERT ... VALUES without any rows.
+	if len(batch) == 0 {
+		return nil
+	}
+	values := make([]string, len(batch))
+	for i, data := range batch {
+		values[i] = fmt.Sprintf("(%d, %s, '%s')", data.Key, quote(data.Text), data.Timestamp.UTC().Format(timestampLayout))
+	}
+	insertQuery := fmt.Sprintf("INSERT INTO %s (id, text, timestamp) VALUES %s", db.testdata(), strings.Join(values, ", "))
+	_, err := db.ExecContext(ctx, insertQuery)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		return err
+	}
+
+	return nil
+}
+
+func (db PlanetScale) ReadTestDataBatch(ctx context.Context, ids []int64) ([]test.TestData, error) {
+	// MySQL cannot parse an empty IN () list.
+	if len(ids) == 0 {
+		return []test.TestData{}, nil
+	}
+	idList := make([]string, len(ids))
+	for i, id := range ids {
+		idList[i] = fmt.Sprint(id)
+	}
+	query := fmt.Sprintf("SELECT id, text, timestamp FROM %s WHERE id IN (%s);", db.testdata(), strings.Join(idList, ", "))
+	response, err := db.ExecContext(ctx, query)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return nil, err
+	}
+	data, err := extractTestData(*response)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to extract test data from response [%v]: %s\n", response, err)
+		return nil, err
+	}
+	return data, nil
+}
+
+func (db PlanetScale) UpdateTestData(ctx context.Context, data test.TestData) error {
+	updateQuery := fmt.Sprintf("UPDATE %s SET text = %s, timestamp = '%s' WHERE id = %d", db.testdata(), quote(data.Text), data.Timestamp.UTC().Format(timestampLayout), data.Key)
+	_, err := db.ExecContext(ctx, updateQuery)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		return err
+	}
+
+	return nil
+}
+
+func (db PlanetScale) DeleteTestData(ctx context.Context, id int64) error {
+	deleteQuery := fmt.Sprintf("DELETE FROM %s WHERE id = %d", db.testdata(), id)
+	_, err := db.ExecContext(ctx, deleteQuery)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
 		return err
@@ -80,45 +240,161 @@ func (db PlanetScale) WriteTestData(data test.TestData) error {
 	return nil
 }
 
+func (db PlanetScale) ResetCounter(ctx context.Context, id int64) error {
+	_, err := db.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (id, value) VALUES (%d, 0) ON DUPLICATE KEY UPDATE value = 0", db.counters(), id))
+	return err
+}
+
+// Reads the counter with SELECT ... FOR UPDATE and writes it back one higher in
+// a transaction. The transaction's statements share a session. Deadlocks and
+// lock wait timeouts abort the transaction and are returned as conflicts.
+func (db PlanetScale) IncrementCounter(ctx context.Context, id int64) error {
+	_, session, err := db.execSession(ctx, nil, "BEGIN")
+	if err != nil {
+		return err
+	}
+	// Failed requests come back without a session, so only a returned session
+	// replaces the one holding the transaction and its row lock.
+	exec := func(sql string) (*QueryResponse, error) {
+		response, next, err := db.execSession(ctx, session, sql)
+		if next != nil {
+			session = next
+		}
+		return response, err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			db.execSession(context.WithoutCancel(ctx), session, "ROLLBACK")
+		}
+	}()
+	response, err := exec(fmt.Sprintf("SELECT value FROM %s WHERE id = %d FOR UPDATE", db.counters(), id))
+	if err != nil {
+		return conflict(err)
+	}
+	rows, err := parseRows(*response)
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return test.ErrNotFound
+	}
+	value, ok := rows[0]["value"].(int64)
+	if !ok {
+		return fmt.Errorf("Counter %d has a value of type %T.", id, rows[0]["value"])
+	}
+	if _, err := exec(fmt.Sprintf("UPDATE %s SET value = %d WHERE id = %d", db.counters(), value+1, id)); err != nil {
+		return conflict(err)
+	}
+	if _, err := exec("COMMIT"); err != nil {
+		return conflict(err)
+	}
+	committed = true
+	return nil
+}
+
+func (db PlanetScale) ReadCounter(ctx context.Context, id int64) (int64, error) {
+	response, err := db.ExecContext(ctx, fmt.Sprintf("SELECT value FROM %s WHERE id = %d", db.counters(), id))
+	if err != nil {
+		return 0, err
+	}
+	rows, err := parseRows(*response)
+	if err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, test.ErrNotFound
+	}
+	value, ok := rows[0]["value"].(int64)
+	if !ok {
+		return 0, fmt.Errorf("Counter %d has a value of type %T.", id, rows[0]["value"])
+	}
+	return value, nil
+}
+
+// Marks deadlocks and lock wait timeouts as transaction conflicts.
+func conflict(err error) error {
+	if message := err.Error(); strings.Contains(message, "Deadlock found") || strings.Contains(message, "Lock wait timeout") {
+		return fmt.Errorf("%w: %s", test.ErrConflict, message)
+	}
+	return err
+}
+
 func (db PlanetScale) Exec(sql string) (*QueryResponse, error) {
+	return db.ExecContext(context.Background(), sql)
+}
+
+func (db PlanetScale) ExecContext(ctx context.Context, sql string) (*QueryResponse, error) {
+	response, _, err := db.execSession(ctx, nil, sql)
+	return response, err
+}
+
+// Runs the query in the session and returns the session to send with the next
+// query. A nil session starts a new one. Transactions only last as long as
+// their session is passed along.
+func (db PlanetScale) execSession(ctx context.Context, session json.RawMessage, sql string) (*QueryResponse, json.RawMessage, error) {
 	// i love you stupid and nerd head, you silly cutie
 	url := db.url
 	method := "POST"
 
+	if session == nil {
+		session = json.RawMessage("null")
+	}
+	query, err := json.Marshal(sql)
+	if err != nil {
+		return nil, nil, err
+	}
 	payload := strings.NewReader(fmt.Sprintf(`{
-    "query": "%s",
-    "session": null
-}`, sql))
+    "query": %s,
+    "session": %s
+}`, query, session))
 	client := &http.Client{}
-	req, err := http.NewRequest(method, url, payload)
+	req, err := http.NewRequestWithContext(ctx, method, url, payload)
 
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
-		return nil, err
+		return nil, nil, err
 	}
 	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Authorization", fmt.Sprintf("Basic %s", os.Getenv("PLANETSCALE_AUTH")))
+	req.Header.Add("Authorization", fmt.Sprintf("Basic %s", db.auth))
 
 	res, err := client.Do(req)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
-		return nil, err
+		return nil, nil, err
 	}
 	defer res.Body.Close()
 
 	body, err := io.ReadAll(res.Body)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
-		return nil, err
+		return nil, nil, err
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, nil, test.HTTPStatusError{StatusCode: res.StatusCode, Body: string(body)}
 	}
 
 	var response QueryResponse
 	err = json.Unmarshal(body, &response)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
-		return nil, err
+		return nil, nil, err
+	}
+	var raw struct {
+		Session json.RawMessage `json:"session"`
+	}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, nil, err
 	}
-	return &response, nil
+	if response.Error != nil {
+		return nil, raw.Session, fmt.Errorf("Query failed with code %s: %s", response.Error.Code, response.Error.Message)
+	}
+	return &response, raw.Session, nil
+}
+
+// Quotes the text as a MySQL string literal.
+func quote(text string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", "''").Replace(text) + "'"
 }
 
 func extractTestData(response QueryResponse) ([]test.TestData, error) {
@@ -182,10 +458,12 @@ func convertValue(sqlType, value string) (any, error) {
 	switch sqlType {
 	case "INT32":
 		return strconv.Atoi(value)
-	case "VARCHAR":
+	case "INT64":
+		return strconv.ParseInt(value, 10, 64)
+	case "VARCHAR", "TEXT":
 		return value, nil
 	case "DATETIME":
-		return time.Parse("2006-01-02 15:04:05", value)
+		return time.Parse(timestampLayout, value)
 	}
 	return nil, fmt.Errorf("No type found for %s.", sqlType)
 }
@@ -0,0 +1,30 @@
+package planetscale
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/timsexperiments/distributed-db-test/internal/test"
+)
+
+func init() {
+	test.Register(test.Adapter{
+		Name:        "planetscale",
+		Description: "PlanetScale (MySQL) over its HTTP query API",
+		Env:         []string{"PLANETSCALE_DB_URL", "PLANETSCALE_AUTH"},
+		Open: func(_ context.Context, runID string) (test.TestDatabase, error) {
+			env, err := test.RequireEnv("PLANETSCALE_DB_URL", "PLANETSCALE_AUTH")
+			if err != nil {
+				return nil, err
+			}
+			if _, err := url.ParseRequestURI(env[0]); err != nil {
+				return nil, fmt.Errorf("PLANETSCALE_DB_URL is not a valid URL: %w", err)
+			}
+			return NewPlanetScaleCleint(env[0], env[1]).WithRunID(runID), nil
+		},
+		Warmup:    100, // The first requests pay for DNS, TCP and TLS setup.
+		OpTimeout: 30 * time.Second,
+	})
+}
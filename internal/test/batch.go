@@ -0,0 +1,176 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// A test database that can write several test data in one request.
+type BatchWriter interface {
+	WriteTestDataBatch(context.Context, []TestData) error // Writes all of the test data in one request.
+}
+
+// A test database that can read several test data in one request.
+type BatchReader interface {
+	ReadTestDataBatch(context.Context, []int64) ([]TestData, error) // Reads the test data for the keys in one request. Keys that are not found are left out.
+}
+
+// The cost of batched writes and reads of one batch size.
+type BatchSweep struct {
+	Size   int    // The number of test data per request.
+	Writes Result // The statistics of the batched writes, if the test database supports them.
+	Reads  Result // The statistics of the batched reads, if the test database supports them.
+}
+
+// Summarises the cost per test data of the batch size.
+func (sweep BatchSweep) String() string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "batch size %d:", sweep.Size)
+	for _, phase := range []struct {
+		name   string
+		result Result
+	}{{"writes", sweep.Writes}, {"reads", sweep.Reads}} {
+		if phase.result.Rows == 0 {
+			continue
+		}
+		fmt.Fprintf(&builder, " %s %.2f rows/s, %s per row, p50 %s per batch;", phase.name, phase.result.RowsPerSecond(), phase.result.PerRow(), phase.result.P50)
+	}
+	return strings.TrimSuffix(builder.String(), ";")
+}
+
+// Writes the tester's total number of batches of new test data, each batch
+// in one request, and returns the latency statistics of the requests. The
+// result counts the test data written in its rows. The error wraps
+// ErrUnsupported if the test database cannot write batches.
+func (tester dbTester) TimeBatchWrites(ctx context.Context, size int) (Result, error) {
+	db, ok := tester.db.(BatchWriter)
+	if !ok {
+		return newResult(NewHistogram()), fmt.Errorf("%T does not implement test.BatchWriter: %w", tester.db, ErrUnsupported)
+	}
+	if err := checkBatchSize(size); err != nil {
+		return newResult(NewHistogram()), err
+	}
+	sequence := func(n int) (int64, bool) { return int64(n), true }
+	return tester.timePrepared(ctx, fmt.Sprintf("writing batches of %d", size), sequence, func(int64) operation {
+		batch := make([]TestData, size)
+		written := 0
+		for i := range batch {
+			batch[i] = tester.newTestData(tester.keys.claim(), "SampleText")
+			written += len(batch[i].Text)
+		}
+		return func(ctx context.Context, _ int64) (outcome, error) {
+			if err := db.WriteTestDataBatch(ctx, batch); err != nil {
+				return outcome{}, err
+			}
+			for _, data := range batch {
+				tester.keys.add(data)
+			}
+			return outcome{rows: size, bytes: written}, nil
+		}
+	})
+}
+
+// Reads the tester's total number of batches of the written test data, each
+// batch in one request, and returns the latency statistics of the requests.
+// The keys are picked with the tester's key distribution, in order by default.
+// The result counts the test data read in its rows. The error wraps
+// ErrUnsupported if the test database cannot read batches.
+func (tester dbTester) TimeBatchReads(ctx context.Context, size int) (Result, error) {
+	db, ok := tester.db.(BatchReader)
+	if !ok {
+		return newResult(NewHistogram()), fmt.Errorf("%T does not implement test.BatchReader: %w", tester.db, ErrUnsupported)
+	}
+	if err := checkBatchSize(size); err != nil {
+		return newResult(NewHistogram()), err
+	}
+	distribution := tester.keyDistributionOr(SequentialKeys())
+	keys := tester.readKeys(newLockedRand(tester.seed), distribution)
+	v := tester.newVerifier()
+	sequence := func(n int) (int64, bool) { return int64(n), true }
+	result, err := tester.timePrepared(ctx, fmt.Sprintf("reading batches of %d", size), sequence, func(op int64) operation {
+		batch := make([]int64, size)
+		before := make([][]TestData, size)
+		for i := range batch {
+			batch[i], _ = keys(int(op)*size + i)
+			before[i] = tester.keys.expected(batch[i])
+		}
+		return func(ctx context.Context, _ int64) (outcome, error) {
+			data, err := db.ReadTestDataBatch(ctx, batch)
+			if err != nil {
+				return outcome{}, err
+			}
+			if v != nil {
+				found := make(map[int64]*TestData, len(data))
+				for i := range data {
+					found[data[i].Key] = &data[i]
+				}
+				for i, key := range batch {
+					var readErr error
+					if found[key] == nil {
+						readErr = ErrNotFound
+					}
+					v.check(key, found[key], readErr, append(before[i], tester.keys.expected(key)...))
+				}
+			}
+			out := outcome{rows: len(data)}
+			for _, read := range data {
+				out.bytes += len(read.Text)
+			}
+			return out, nil
+		}
+	})
+	result.Keys = tester.describeKeys(distribution)
+	if v != nil {
+		result.Verification = v.result()
+	}
+	return result, err
+}
+
+// Runs batched writes and then batched reads for each batch size, so the cost
+// per test data can be compared as batches grow. Each size handles about the
+// same number of test data: the tester's total divided by the batch size
+// requests, or as many as fit in the tester's duration. Phases the test
+// database does not support are left empty, and the error wraps
+// ErrUnsupported if it supports neither. Sizes below 1 are an error.
+func (tester dbTester) SweepBatchSizes(ctx context.Context, sizes []int) ([]BatchSweep, error) {
+	_, canWrite := tester.db.(BatchWriter)
+	_, canRead := tester.db.(BatchReader)
+	if !canWrite && !canRead {
+		return nil, fmt.Errorf("%T implements neither test.BatchWriter nor test.BatchReader: %w", tester.db, ErrUnsupported)
+	}
+	for _, size := range sizes {
+		if err := checkBatchSize(size); err != nil {
+			return nil, err
+		}
+	}
+	sweeps := make([]BatchSweep, 0, len(sizes))
+	for _, size := range sizes {
+		sized := tester
+		if tester.duration == 0 {
+			sized = tester.WithTotal(max(1, tester.total/size))
+		}
+		sweep := BatchSweep{Size: size, Writes: newResult(NewHistogram()), Reads: newResult(NewHistogram())}
+		var err error
+		if canWrite {
+			if sweep.Writes, err = sized.TimeBatchWrites(ctx, size); err != nil {
+				return append(sweeps, sweep), err
+			}
+		}
+		if canRead {
+			if sweep.Reads, err = sized.TimeBatchReads(ctx, size); err != nil {
+				return append(sweeps, sweep), err
+			}
+		}
+		sweeps = append(sweeps, sweep)
+	}
+	return sweeps, nil
+}
+
+// Returns an error if the batch size is below 1.
+func checkBatchSize(size int) error {
+	if size < 1 {
+		return fmt.Errorf("Batch size %d is too small. Every batch needs at least 1 test data.", size)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// A test database taking part in a comparison.
+type Contender struct {
+	Name string       // The name shown in the report.
+	DB   TestDatabase // The test database.
+}
+
+// The side-by-side results of running the same phase against several test
+// databases in interleaved rounds.
+type Comparison struct {
+	Phase   string   // The phase that was compared.
+	Rounds  int      // The number of rounds the phase was split into.
+	Names   []string // The names of the contenders, in the order they were given.
+	Results []Result // The result of each contender over every round, in the same order.
+	Orders  [][]int  // The order the contenders ran in each round, as indexes into Names.
+}
+
+// Runs each phase against every contender in interleaved rounds, shuffling
+// their order each round, and returns the merged result of each contender per
+// phase. If a round fails the comparisons so far are returned with the error.
+func (tester dbTester) Compare(ctx context.Context, contenders []Contender, rounds int, phases ...string) ([]Comparison, error) {
+	rounds = max(1, rounds)
+	names := make([]string, len(contenders))
+	testers := make([]dbTester, len(contenders))
+	for i, contender := range contenders {
+		names[i] = contender.Name
+		testers[i] = tester.forDatabase(contender.DB)
+	}
+
+	random := rand.New(rand.NewSource(tester.seed))
+	comparisons := make([]Comparison, 0, len(phases))
+	for _, phase := range phases {
+		comparison := Comparison{Phase: phase, Rounds: rounds, Names: names, Results: make([]Result, len(contenders))}
+		offsets := make([]int, len(contenders))
+		for round := 0; round < rounds; round++ {
+			order := random.Perm(len(contenders))
+			comparison.Orders = append(comparison.Orders, order)
+			for _, i := range order {
+				result, err := testers[i].forRound(round, rounds, offsets[i]).RunPhase(ctx, phase)
+				offsets[i] += result.operations()
+				if round == 0 {
+					comparison.Results[i] = result
+				} else {
+					comparison.Results[i] = comparison.Results[i].Merge(result)
+				}
+				if err != nil {
+					return append(comparisons, comparison), fmt.Errorf("%s failed %s in round %d: %w", contenders[i].Name, phase, round+1, err)
+				}
+			}
+		}
+		comparisons = append(comparisons, comparison)
+	}
+	return comparisons, nil
+}
+
+// Creates a copy of the tester for another test database, with keys of its own.
+func (tester dbTester) forDatabase(db TestDatabase) dbTester {
+	tester.db = db
+	tester.keys = newKeySpace()
+	return tester
+}
+
+// Creates a copy of the tester that runs the given round's share of the
+// operations, starting after the operations earlier rounds ran. Each round gets
+// its own seed. Only the first round warms up.
+func (tester dbTester) forRound(round int, rounds int, offset int) dbTester {
+	if tester.duration > 0 {
+		tester.duration /= time.Duration(rounds)
+	} else {
+		// Hand out the remainder to the first rounds so the shares add up to the total.
+		share := tester.total / rounds
+		if round < tester.total%rounds {
+			share++
+		}
+		tester.total = share
+	}
+	if round > 0 {
+		tester.warmup, tester.warmupDuration = 0, 0
+	}
+	tester.offset = offset
+	tester.seed += int64(round)
+	return tester
+}
+
+// Returns the number of operations the run handed out keys for, including its
+// warmup.
+func (result Result) operations() int {
+	count := result.Count + result.Failed
+	if result.Warmup != nil {
+		count += result.Warmup.operations()
+	}
+	return count
+}
+
+// Shows the main statistics of each contender side by side, with how much
+// each one differs from the first contender.
+func (comparison Comparison) String() string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "%s compared over %d interleaved rounds\n", comparison.Phase, comparison.Rounds)
+	writer := tabwriter.NewWriter(&builder, 0, 0, 2, ' ', 0)
+	fmt.Fprint(writer, "\t"+strings.Join(comparison.Names, "\t")+"\n")
+	for _, statistic := range trialStatistics {
+		fmt.Fprint(writer, statistic.name)
+		var baseline float64
+		for i, result := range comparison.Results {
+			value := statistic.value(result)
+			cell := Estimate{Unit: statistic.unit}.format(value)
+			if i == 0 {
+				baseline = value
+			} else if baseline != 0 {
+				cell += fmt.Sprintf(" (%+.1f%%)", (value-baseline)/baseline*100)
+			}
+			fmt.Fprint(writer, "\t"+cell)
+		}
+		fmt.Fprintln(writer)
+	}
+	writer.Flush()
+	return strings.TrimSuffix(builder.String(), "\n")
+}
@@ -0,0 +1,63 @@
+package test
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+// A test database that keeps its test data in memory.
+type memoryDatabase struct {
+	data sync.Map
+}
+
+func (db *memoryDatabase) WriteTestData(data TestData) error {
+	db.data.Store(data.Key, data)
+	return nil
+}
+
+func (db *memoryDatabase) ReadTestData(key int64) (*TestData, error) {
+	data, ok := db.data.Load(key)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	result := data.(TestData)
+	return &result, nil
+}
+
+func (db *memoryDatabase) DeleteTestData(_ context.Context, key int64) error {
+	db.data.Delete(key)
+	return nil
+}
+
+func (db *memoryDatabase) len() int {
+	count := 0
+	db.data.Range(func(any, any) bool {
+		count++
+		return true
+	})
+	return count
+}
+
+func TestCompareDeletesEveryKeyOverTheRounds(t *testing.T) {
+	a, b := &memoryDatabase{}, &memoryDatabase{}
+	contenders := []Contender{{Name: "a", DB: a}, {Name: "b", DB: b}}
+	tester := NewDbTester(nil).WithTotal(100).WithWaitGroup(10).WithSeed(1)
+	comparisons, err := tester.Compare(context.Background(), contenders, 4, PhaseWrites, PhaseDeletes, PhaseReads)
+	if err != nil {
+		t.Fatalf("compare failed: %v", err)
+	}
+	deletes, reads := comparisons[1], comparisons[2]
+	for i, contender := range contenders {
+		db := contender.DB.(*memoryDatabase)
+		if left := db.len(); left != 0 {
+			t.Errorf("%s: %d test data left after deleting, want 0", contender.Name, left)
+		}
+		if deleted := deletes.Results[i].Count; deleted != 100 {
+			t.Errorf("%s: deleted %d test data, want 100", contender.Name, deleted)
+		}
+		if read := reads.Results[i].Count; read != 0 {
+			t.Errorf("%s: read %d deleted test data, want 0", contender.Name, read)
+		}
+	}
+}
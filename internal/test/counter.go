@@ -0,0 +1,142 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Returned by a test database when a transaction was aborted because another
+// transaction changed the same data. The operation can be retried.
+var ErrConflict = errors.New("The transaction conflicted with another one.")
+
+// The number of times a conflicting counter increment is retried before it
+// counts as failed.
+const testCounterRetriesDefault = 10
+
+// A test database with counters that can be incremented with a transactional
+// read-modify-write. Counters are kept apart from the test data.
+type Counter interface {
+	ResetCounter(context.Context, int64) error         // Creates the counter with the given key, or sets it back to zero.
+	IncrementCounter(context.Context, int64) error     // Reads the counter and writes it back one higher in one transaction. The error wraps ErrConflict if the transaction was aborted.
+	ReadCounter(context.Context, int64) (int64, error) // Returns the value of the counter.
+}
+
+// How the counters held up under contention.
+type Contention struct {
+	Counters  int   // The number of hot counters the increments were spread over.
+	Committed int64 // The increments that committed, including any warmup.
+	Retries   int64 // The attempts that were aborted because of a conflict and retried.
+	Aborted   int64 // The increments that still conflicted after every retry.
+	Unknown   int64 // The increments that failed in a way that may or may not have committed, such as a timeout.
+	Expected  int64 // The sum of the counters if every committed increment was kept.
+	Actual    int64 // The sum of the counters read back after the run.
+}
+
+// The number of committed increments missing from the counters.
+func (contention Contention) Lost() int64 {
+	if lost := contention.Expected - contention.Actual; lost > 0 {
+		return lost
+	}
+	return 0
+}
+
+func (contention Contention) String() string {
+	status := "no lost updates"
+	switch {
+	case contention.Lost() > 0:
+		status = fmt.Sprintf("%d LOST UPDATES", contention.Lost())
+	case contention.Actual > contention.Expected+contention.Unknown:
+		status = fmt.Sprintf("%d more increments than were sent", contention.Actual-contention.Expected-contention.Unknown)
+	}
+	return fmt.Sprintf("contention: %d counters, %d committed, %d retries, %d aborted after %d retries, %d unknown; expected %d, read %d: %s",
+		contention.Counters, contention.Committed, contention.Retries, contention.Aborted, testCounterRetriesDefault, contention.Unknown, contention.Expected, contention.Actual, status)
+}
+
+// Adds the counts of the other contention to this one.
+func (contention *Contention) merge(other *Contention) *Contention {
+	if contention == nil {
+		return other
+	}
+	if other == nil {
+		return contention
+	}
+	merged := *contention
+	merged.Committed += other.Committed
+	merged.Retries += other.Retries
+	merged.Aborted += other.Aborted
+	merged.Unknown += other.Unknown
+	merged.Expected += other.Expected
+	merged.Actual += other.Actual
+	return &merged
+}
+
+// Resets the given number of counters, increments them concurrently and
+// returns the latency statistics with the lost updates in its contention. The
+// error wraps ErrUnsupported if the test database has no counters.
+func (tester dbTester) TimeCounters(ctx context.Context, counters int) (Result, error) {
+	db, ok := tester.db.(Counter)
+	if !ok {
+		return newResult(NewHistogram()), fmt.Errorf("%T does not implement test.Counter: %w", tester.db, ErrUnsupported)
+	}
+	if counters < 1 {
+		return newResult(NewHistogram()), fmt.Errorf("%d counters are too few. The counters phase needs at least 1 counter.", counters)
+	}
+	for key := int64(1); key <= int64(counters); key++ {
+		if err := db.ResetCounter(ctx, key); err != nil {
+			return newResult(NewHistogram()), fmt.Errorf("Unable to reset counter %d: %w", key, err)
+		}
+	}
+
+	var mutex sync.Mutex
+	contention := Contention{Counters: counters}
+	distribution := tester.keyDistributionOr(UniformKeys())
+	random := newLockedRand(tester.seed)
+	keys := func(n int) (int64, bool) {
+		return int64(random.index(distribution, n, counters) + 1), true
+	}
+	result, err := tester.timeOperations(ctx, "incrementing counters", keys, func(ctx context.Context, key int64) (outcome, error) {
+		retries, err := tester.increment(ctx, db, key)
+		mutex.Lock()
+		defer mutex.Unlock()
+		contention.Retries += int64(retries)
+		switch {
+		case err == nil:
+			contention.Committed++
+		case errors.Is(err, ErrConflict):
+			contention.Aborted++
+		default:
+			contention.Unknown++
+		}
+		return outcome{}, err
+	})
+
+	// Read the counters back even if the run was canceled, since the
+	// increments that did commit should all be there.
+	readCtx := context.WithoutCancel(ctx)
+	for key := int64(1); key <= int64(counters); key++ {
+		value, readErr := db.ReadCounter(readCtx, key)
+		if readErr != nil {
+			return result, errors.Join(err, fmt.Errorf("Unable to read counter %d: %w", key, readErr))
+		}
+		contention.Actual += value
+	}
+	contention.Expected = contention.Committed
+	result.Keys = tester.describeKeys(distribution)
+	result.Contention = &contention
+	return result, err
+}
+
+// Increments the counter, retrying while the transaction conflicts. Returns
+// the number of retries.
+func (tester dbTester) increment(ctx context.Context, db Counter, key int64) (int, error) {
+	retries := 0
+	for {
+		err := db.IncrementCounter(ctx, key)
+		if !errors.Is(err, ErrConflict) || retries == testCounterRetriesDefault || ctx.Err() != nil {
+			return retries, err
+		}
+		retries++
+	}
+}
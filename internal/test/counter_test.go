@@ -0,0 +1,39 @@
+package test
+
+import (
+	"context"
+	"testing"
+)
+
+// A test database whose counters count how often they were touched.
+type countingDatabase struct {
+	memoryDatabase
+	calls int
+}
+
+func (db *countingDatabase) ResetCounter(context.Context, int64) error {
+	db.calls++
+	return nil
+}
+
+func (db *countingDatabase) IncrementCounter(context.Context, int64) error {
+	db.calls++
+	return nil
+}
+
+func (db *countingDatabase) ReadCounter(context.Context, int64) (int64, error) {
+	db.calls++
+	return 0, nil
+}
+
+func TestTimeCountersRejectsCountsBelowOne(t *testing.T) {
+	for _, counters := range []int{0, -1} {
+		db := &countingDatabase{}
+		if _, err := NewDbTester(db).WithTotal(10).TimeCounters(context.Background(), counters); err == nil {
+			t.Errorf("%d counters: no error", counters)
+		}
+		if db.calls != 0 {
+			t.Errorf("%d counters: touched the counters %d times before failing", counters, db.calls)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package test
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Picks which of the written keys each operation targets. Keys are numbered by
+// index from 0 to count-1 in the order they were written, so higher indexes
+// are more recent. Calls to Next are never made concurrently with the same
+// random number generator.
+type KeyDistribution interface {
+	Next(random *rand.Rand, op int, count int) int // Returns the key index for the op-th operation.
+	String() string                                // Describes the distribution for reports.
+}
+
+// Picks the keys in order, starting over once every key has been picked.
+type sequentialKeys struct{}
+
+// Creates a distribution that picks the keys in order. This is the default for reads.
+func SequentialKeys() KeyDistribution {
+	return sequentialKeys{}
+}
+
+func (sequentialKeys) Next(_ *rand.Rand, op int, count int) int {
+	return op % count
+}
+
+func (sequentialKeys) String() string {
+	return "sequential"
+}
+
+// Picks every key with the same probability.
+type uniformKeys struct{}
+
+// Creates a distribution that picks every key with the same probability.
+func UniformKeys() KeyDistribution {
+	return uniformKeys{}
+}
+
+func (uniformKeys) Next(random *rand.Rand, _ int, count int) int {
+	return int(random.Int63n(int64(count)))
+}
+
+func (uniformKeys) String() string {
+	return "uniform"
+}
+
+// Picks keys with a zipfian distribution, so a few keys get most of the
+// traffic. The oldest keys are the most popular.
+type zipfianKeys struct {
+	mutex  sync.Mutex
+	theta  float64 // The skew, between 0 and 1. Higher values are more skewed.
+	count  int     // The number of keys zeta has been computed for.
+	zetan  float64 // The zeta constant for count keys.
+	zeta2  float64 // The zeta constant for 2 keys.
+	latest bool    // Whether the newest keys are the most popular instead.
+}
+
+// Creates a zipfian distribution with the given skew, which must be between 0
+// and 1, exclusive. YCSB uses 0.99.
+func ZipfianKeys(theta float64) KeyDistribution {
+	return &zipfianKeys{theta: theta, zeta2: zeta(0, 2, theta, 0)}
+}
+
+// Creates a zipfian distribution where the most recently written keys are the
+// most popular, like YCSB's "latest" distribution.
+func LatestKeys(theta float64) KeyDistribution {
+	return &zipfianKeys{theta: theta, zeta2: zeta(0, 2, theta, 0), latest: true}
+}
+
+// Picks a key using the algorithm from "Quickly Generating Billion-Record
+// Synthetic Databases" by Gray et al., which YCSB also uses. The zeta constant
+// is extended as the key space grows instead of being computed from scratch.
+func (keys *zipfianKeys) Next(random *rand.Rand, _ int, count int) int {
+	keys.mutex.Lock()
+	if count != keys.count {
+		if count > keys.count {
+			keys.zetan = zeta(keys.count, count, keys.theta, keys.zetan)
+		} else {
+			keys.zetan = zeta(0, count, keys.theta, 0)
+		}
+		keys.count = count
+	}
+	zetan := keys.zetan
+	keys.mutex.Unlock()
+
+	alpha := 1 / (1 - keys.theta)
+	eta := (1 - math.Pow(2/float64(count), 1-keys.theta)) / (1 - keys.zeta2/zetan)
+	u := random.Float64()
+	uz := u * zetan
+	var index int
+	switch {
+	case uz < 1:
+		index = 0
+	case uz < 1+math.Pow(0.5, keys.theta):
+		index = 1
+	default:
+		index = int(float64(count) * math.Pow(eta*u-eta+1, alpha))
+	}
+	if index >= count {
+		index = count - 1
+	}
+	if keys.latest {
+		return count - 1 - index
+	}
+	return index
+}
+
+// Returns a copy of the distribution without the zeta constant computed so
+// far, which is only valid for the key count of the run that computed it.
+// Distributions without state are returned as they are.
+func freshKeys(keys KeyDistribution) KeyDistribution {
+	if zipfian, ok := keys.(*zipfianKeys); ok {
+		return &zipfianKeys{theta: zipfian.theta, zeta2: zipfian.zeta2, latest: zipfian.latest}
+	}
+	return keys
+}
+
+func (keys *zipfianKeys) String() string {
+	if keys.latest {
+		return fmt.Sprintf("latest (theta %.2f)", keys.theta)
+	}
+	return fmt.Sprintf("zipfian (theta %.2f)", keys.theta)
+}
+
+// Extends the zeta constant sum of 1/i^theta from the first count keys to n keys.
+func zeta(count int, n int, theta float64, sum float64) float64 {
+	for i := count; i < n; i++ {
+		sum += 1 / math.Pow(float64(i+1), theta)
+	}
+	return sum
+}
+
+// Sends a fraction of the operations to a fraction of the keys and spreads the
+// rest uniformly over the other keys.
+type hotspotKeys struct {
+	hotKeys float64 // The fraction of keys that are hot.
+	hotOps  float64 // The fraction of operations that go to the hot keys.
+}
+
+// Creates a hotspot distribution where hotOps of the operations go to hotKeys
+// of the keys. For example HotspotKeys(0.2, 0.8) sends 80% of the traffic to
+// 20% of the keys.
+func HotspotKeys(hotKeys float64, hotOps float64) KeyDistribution {
+	return hotspotKeys{hotKeys: hotKeys, hotOps: hotOps}
+}
+
+func (keys hotspotKeys) Next(random *rand.Rand, _ int, count int) int {
+	hotCount := int(math.Ceil(float64(count) * keys.hotKeys))
+	if hotCount > count {
+		hotCount = count
+	}
+	if hotCount == count || (hotCount > 0 && random.Float64() < keys.hotOps) {
+		return int(random.Int63n(int64(hotCount)))
+	}
+	return hotCount + int(random.Int63n(int64(count-hotCount)))
+}
+
+func (keys hotspotKeys) String() string {
+	return fmt.Sprintf("hotspot (%.0f%% of operations to %.0f%% of keys)", keys.hotOps*100, keys.hotKeys*100)
+}
+
+// Reads a key distribution from its short form: "sequential", "uniform",
+// "zipfian:THETA", "latest:THETA" or "hotspot:HOT_KEYS:HOT_OPS", for example
+// "zipfian:0.99" or "hotspot:0.2:0.8". The parameters can be left out for
+// YCSB's defaults. THETA must be between 0 and 1, exclusive, and HOT_KEYS and
+// HOT_OPS between 0 and 1, inclusive.
+func ParseKeyDistribution(spec string) (KeyDistribution, error) {
+	name, parameters, err := parseSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case name == "sequential" && len(parameters) == 0:
+		return SequentialKeys(), nil
+	case name == "uniform" && len(parameters) == 0:
+		return UniformKeys(), nil
+	case (name == "zipfian" || name == "latest") && len(parameters) <= 1:
+		theta := parameterOr(parameters, 0, 0.99)
+		if !(theta > 0 && theta < 1) {
+			return nil, fmt.Errorf("The theta of %q must be above 0 and below 1, for example %s:0.99.", spec, name)
+		}
+		if name == "latest" {
+			return LatestKeys(theta), nil
+		}
+		return ZipfianKeys(theta), nil
+	case name == "hotspot" && len(parameters) <= 2:
+		hotKeys, hotOps := parameterOr(parameters, 0, 0.2), parameterOr(parameters, 1, 0.8)
+		if !(hotKeys >= 0 && hotKeys <= 1 && hotOps >= 0 && hotOps <= 1) {
+			return nil, fmt.Errorf("The fractions of %q must be between 0 and 1, for example hotspot:0.2:0.8.", spec)
+		}
+		return HotspotKeys(hotKeys, hotOps), nil
+	}
+	return nil, fmt.Errorf("Unknown key distribution %q. Use sequential, uniform, zipfian:THETA, latest:THETA or hotspot:HOT_KEYS:HOT_OPS.", spec)
+}
+
+// Splits a short form like "zipfian:0.99" into its name and numeric parameters.
+func parseSpec(spec string) (string, []float64, error) {
+	parts := strings.Split(spec, ":")
+	parameters := make([]float64, len(parts)-1)
+	for i, part := range parts[1:] {
+		parameter, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return "", nil, fmt.Errorf("Parameter %q of %q is not a number.", part, spec)
+		}
+		parameters[i] = parameter
+	}
+	return parts[0], parameters, nil
+}
+
+// Returns the i-th parameter, or the fallback if it was left out.
+func parameterOr(parameters []float64, i int, fallback float64) float64 {
+	if i < len(parameters) {
+		return parameters[i]
+	}
+	return fallback
+}
@@ -0,0 +1,99 @@
+package test
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// Picks keys from the distribution and counts how often each index came up.
+func sample(distribution KeyDistribution, count int, picks int) []int {
+	random := rand.New(rand.NewSource(1))
+	counts := make([]int, count)
+	for op := 0; op < picks; op++ {
+		counts[distribution.Next(random, op, count)]++
+	}
+	return counts
+}
+
+func TestKeyDistributions(t *testing.T) {
+	const count, picks = 1000, 100000
+	tests := []struct {
+		spec        string
+		minDistinct int // The fewest distinct keys the picks must touch.
+		hottest     int // The index that must be picked most often, or -1 for any.
+	}{
+		{"sequential", count, -1},
+		{"uniform", 990, -1},
+		{"zipfian", 500, 0},
+		{"zipfian:0.5", 900, 0},
+		{"latest", 500, count - 1},
+		{"hotspot:0.2:0.8", 990, -1},
+		{"hotspot:0:1", 990, -1},
+		{"hotspot:1:0", 990, -1},
+	}
+	for _, test := range tests {
+		distribution, err := ParseKeyDistribution(test.spec)
+		if err != nil {
+			t.Fatalf("%s: %v", test.spec, err)
+		}
+		counts := sample(distribution, count, picks)
+		distinct, hottest := 0, 0
+		for index, picked := range counts {
+			if picked > 0 {
+				distinct++
+			}
+			if picked > counts[hottest] {
+				hottest = index
+			}
+		}
+		if distinct < test.minDistinct {
+			t.Errorf("%s touched %d distinct keys, want at least %d", test.spec, distinct, test.minDistinct)
+		}
+		if test.hottest >= 0 && hottest != test.hottest {
+			t.Errorf("%s picked index %d most often, want %d", test.spec, hottest, test.hottest)
+		}
+	}
+}
+
+func TestHotspotShare(t *testing.T) {
+	counts := sample(HotspotKeys(0.2, 0.8), 1000, 100000)
+	hot := 0
+	for _, picked := range counts[:200] {
+		hot += picked
+	}
+	if share := float64(hot) / 100000; share < 0.78 || share > 0.82 {
+		t.Errorf("the hot keys got %.3f of the picks, want about 0.8", share)
+	}
+}
+
+func TestZipfianGrowingKeySpace(t *testing.T) {
+	shared := ZipfianKeys(0.99)
+	random := rand.New(rand.NewSource(1))
+	for _, count := range []int{10, 1000, 10, 5000} {
+		for op := 0; op < 1000; op++ {
+			if index := shared.Next(random, op, count); index < 0 || index >= count {
+				t.Fatalf("count %d: index %d out of range", count, index)
+			}
+		}
+	}
+}
+
+func TestParseKeyDistributionRejects(t *testing.T) {
+	for _, spec := range []string{
+		"", "zipf", "uniform:1", "zipfian:1", "zipfian:0", "zipfian:-0.5", "latest:1.5",
+		"hotspot:-0.5:0.8", "hotspot:0.2:1.1", "hotspot:0.2:0.8:1", "zipfian:x", "zipfian:NaN", "hotspot:NaN:0.5",
+	} {
+		if _, err := ParseKeyDistribution(spec); err == nil {
+			t.Errorf("%q was accepted", spec)
+		}
+	}
+}
+
+func TestFreshKeysDropsCachedZeta(t *testing.T) {
+	shared := ZipfianKeys(0.99).(*zipfianKeys)
+	shared.Next(rand.New(rand.NewSource(1)), 0, 100)
+	fresh := freshKeys(shared).(*zipfianKeys)
+	if fresh == shared || fresh.count != 0 || fresh.theta != shared.theta {
+		t.Errorf("fresh copy %+v still shares state with %+v", fresh, shared)
+	}
+}
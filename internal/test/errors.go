@@ -0,0 +1,74 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Returned by a test database when the requested test data does not exist.
+var ErrNotFound = errors.New("Test data not found.")
+
+// Returned by a timed run that stopped early because too many operations failed.
+var ErrAborted = errors.New("Run aborted because the error rate exceeded the maximum.")
+
+// Returned by a test database when an HTTP request came back with an unsuccessful status.
+type HTTPStatusError struct {
+	StatusCode int    // The HTTP status code of the response.
+	Body       string // The body of the response.
+}
+
+func (err HTTPStatusError) Error() string {
+	return fmt.Sprintf("Request failed with status %d: %s", err.StatusCode, err.Body)
+}
+
+// The classes that failed operations are grouped into.
+const (
+	ErrorClassTimeout  = "timeout"
+	ErrorClassNotFound = "not found"
+	ErrorClassDriver   = "driver"
+	ErrorClassConflict = "conflict"
+)
+
+// Sorts an operation error into an error class. HTTP errors are classed by
+// their status code, for example "http 429".
+func ClassifyError(err error) string {
+	var statusErr HTTPStatusError
+	var netErr net.Error
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return ErrorClassNotFound
+	case errors.Is(err, ErrConflict):
+		return ErrorClassConflict
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded):
+		return ErrorClassTimeout
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorClassTimeout
+	case errors.As(err, &statusErr):
+		return fmt.Sprintf("http %d", statusErr.StatusCode)
+	}
+	return ErrorClassDriver
+}
+
+// Formats the error counts by class, most frequent first.
+func formatErrors(errorCounts map[string]int) string {
+	classes := make([]string, 0, len(errorCounts))
+	for class := range errorCounts {
+		classes = append(classes, class)
+	}
+	sort.Slice(classes, func(i, j int) bool {
+		if errorCounts[classes[i]] != errorCounts[classes[j]] {
+			return errorCounts[classes[i]] > errorCounts[classes[j]]
+		}
+		return classes[i] < classes[j]
+	})
+	parts := make([]string, len(classes))
+	for i, class := range classes {
+		parts[i] = fmt.Sprintf("%s x%d", class, errorCounts[class])
+	}
+	return strings.Join(parts, ", ")
+}
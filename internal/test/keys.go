@@ -0,0 +1,211 @@
+package test
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// The keys a tester has handed out and written. Every copy of a tester shares
+// the same key space, so reads after writes target the keys that exist.
+type keySpace struct {
+	mutex   sync.Mutex
+	last    int64              // The last key handed out for writing.
+	written []int64            // The keys that were written successfully.
+	data    map[int64]TestData // The test data last written for each key.
+	pending map[int64]TestData // The test data of updates that are in flight.
+}
+
+func newKeySpace() *keySpace {
+	return &keySpace{written: make([]int64, 0), data: make(map[int64]TestData), pending: make(map[int64]TestData)}
+}
+
+// Hands out the next unused key for writing.
+func (keys *keySpace) claim() int64 {
+	keys.mutex.Lock()
+	defer keys.mutex.Unlock()
+	keys.last++
+	return keys.last
+}
+
+// Marks the test data's key as written.
+func (keys *keySpace) add(data TestData) {
+	keys.mutex.Lock()
+	defer keys.mutex.Unlock()
+	keys.written = append(keys.written, data.Key)
+	keys.data[data.Key] = data
+}
+
+// Marks an update of an existing key as in flight.
+func (keys *keySpace) start(data TestData) {
+	keys.mutex.Lock()
+	defer keys.mutex.Unlock()
+	keys.pending[data.Key] = data
+}
+
+// Marks an in-flight update as finished, replacing the test data written for
+// the key if it succeeded.
+func (keys *keySpace) finish(data TestData, succeeded bool) {
+	keys.mutex.Lock()
+	defer keys.mutex.Unlock()
+	delete(keys.pending, data.Key)
+	if succeeded {
+		keys.data[data.Key] = data
+	}
+}
+
+// Returns the test data a read of the key may see right now: what was last
+// written and what an update in flight is writing.
+func (keys *keySpace) expected(key int64) []TestData {
+	keys.mutex.Lock()
+	defer keys.mutex.Unlock()
+	expected := make([]TestData, 0, 2)
+	if data, ok := keys.data[key]; ok {
+		expected = append(expected, data)
+	}
+	if data, ok := keys.pending[key]; ok {
+		expected = append(expected, data)
+	}
+	return expected
+}
+
+// Returns a written key picked from the distribution.
+func (keys *keySpace) pick(random *lockedRand, distribution KeyDistribution, op int) int64 {
+	keys.mutex.Lock()
+	defer keys.mutex.Unlock()
+	return keys.written[random.index(distribution, op, len(keys.written))]
+}
+
+// Removes deleted keys from the key space.
+func (keys *keySpace) remove(key int64) {
+	keys.mutex.Lock()
+	defer keys.mutex.Unlock()
+	for i, written := range keys.written {
+		if written == key {
+			keys.written = append(keys.written[:i], keys.written[i+1:]...)
+			break
+		}
+	}
+	delete(keys.data, key)
+}
+
+// Returns the written keys in order.
+func (keys *keySpace) snapshot() []int64 {
+	keys.mutex.Lock()
+	defer keys.mutex.Unlock()
+	sorted := append([]int64{}, keys.written...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted
+}
+
+// Returns the key for the n-th operation of a run, counting from zero, or false
+// if there are no keys left.
+type keyFunc func(n int) (int64, bool)
+
+// Hands out the keys for a single run until its operation count or its
+// duration runs out.
+type keySource struct {
+	mutex    sync.Mutex
+	issued   int       // The number of keys handed out so far.
+	total    int       // The number of keys to hand out when there is no deadline.
+	deadline time.Time // When to stop handing out keys. Zero when the run is bounded by total.
+	key      keyFunc   // Returns the key for each operation.
+}
+
+// Creates a key source that hands out total keys, or keys until the duration
+// has passed if it is set, starting now.
+func newKeySource(total int, duration time.Duration, key keyFunc) *keySource {
+	source := &keySource{total: total, key: key}
+	if duration > 0 {
+		source.deadline = time.Now().Add(duration)
+	}
+	return source
+}
+
+// Creates a key source that carries on from where the other one stopped.
+func (source *keySource) after(total int, duration time.Duration) *keySource {
+	offset := source.issued
+	return newKeySource(total, duration, func(n int) (int64, bool) {
+		return source.key(n + offset)
+	})
+}
+
+// Returns the next key, or false once the run is over.
+func (source *keySource) next() (int64, bool) {
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+	if source.exhausted() {
+		return 0, false
+	}
+	key, ok := source.key(source.issued)
+	if !ok {
+		// Out of keys, so the run is over even if time is left.
+		source.total, source.deadline = source.issued, time.Time{}
+		return 0, false
+	}
+	source.issued++
+	return key, true
+}
+
+// Whether the run is over.
+func (source *keySource) done() bool {
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+	return source.exhausted()
+}
+
+func (source *keySource) exhausted() bool {
+	if source.deadline.IsZero() {
+		return source.issued >= source.total
+	}
+	return !time.Now().Before(source.deadline)
+}
+
+// Returns the keys for writing: fresh keys from the key space, so the key space
+// grows for as long as the run goes on.
+func (tester dbTester) writeKeys() keyFunc {
+	return func(int) (int64, bool) {
+		return tester.keys.claim(), true
+	}
+}
+
+// Returns the keys for reading, picked from the written keys with the
+// distribution. If nothing was written by this tester the keys are picked from
+// 1 to total instead.
+func (tester dbTester) readKeys(random *lockedRand, distribution KeyDistribution) keyFunc {
+	written := tester.keys.snapshot()
+	return func(n int) (int64, bool) {
+		if len(written) == 0 {
+			return int64(random.index(distribution, n, tester.total) + 1), true
+		}
+		return written[random.index(distribution, n, len(written))], true
+	}
+}
+
+// Returns the last key handed out. No written key is larger.
+func (keys *keySpace) newest() int64 {
+	keys.mutex.Lock()
+	defer keys.mutex.Unlock()
+	return keys.last
+}
+
+// Creates an empty key space that hands out keys after the ones this key space
+// has handed out, so phases can be timed against their own test data.
+func (keys *keySpace) fork() *keySpace {
+	forked := newKeySpace()
+	forked.last = keys.newest()
+	return forked
+}
+
+// Adds the keys handed out and written in a forked key space to this one.
+func (keys *keySpace) join(forked *keySpace) {
+	forked.mutex.Lock()
+	defer forked.mutex.Unlock()
+	keys.mutex.Lock()
+	defer keys.mutex.Unlock()
+	keys.last = max(keys.last, forked.last)
+	keys.written = append(keys.written, forked.written...)
+	for key, data := range forked.data {
+		keys.data[key] = data
+	}
+}
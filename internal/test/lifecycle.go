@@ -0,0 +1,95 @@
+package test
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// A test database that prepares itself before any phase runs, for example by
+// creating its tables.
+type Setuper interface {
+	Setup(context.Context) error // Creates whatever the phases need, replacing data left by earlier runs.
+}
+
+// A test database that cleans up after the phases have run, for example by
+// dropping its tables.
+type Teardowner interface {
+	Teardown(context.Context) error // Removes whatever the phases wrote.
+}
+
+// Sets up the test database, runs the phases, and tears it down and closes it
+// even if they fail. Returns the errors of every step that failed.
+func (tester dbTester) Run(ctx context.Context, phases func(ctx context.Context) error) error {
+	if err := tester.Setup(ctx); err != nil {
+		return errors.Join(err, tester.Close())
+	}
+	if err := tester.CheckSchema(ctx); err != nil {
+		return errors.Join(err, tester.Teardown(context.WithoutCancel(ctx)), tester.Close())
+	}
+	err := phases(ctx)
+	return errors.Join(err, tester.Teardown(context.WithoutCancel(ctx)), tester.Close())
+}
+
+// Sets up the test database if it implements Setuper.
+func (tester dbTester) Setup(ctx context.Context) error {
+	if db, ok := tester.db.(Setuper); ok {
+		if err := db.Setup(ctx); err != nil {
+			return fmt.Errorf("Unable to set up %T: %w", tester.db, err)
+		}
+	}
+	return nil
+}
+
+// Tears the test database down if it implements Teardowner.
+func (tester dbTester) Teardown(ctx context.Context) error {
+	if db, ok := tester.db.(Teardowner); ok {
+		if err := db.Teardown(ctx); err != nil {
+			return fmt.Errorf("Unable to tear down %T: %w", tester.db, err)
+		}
+	}
+	return nil
+}
+
+// Closes the test database if it implements io.Closer.
+func (tester dbTester) Close() error {
+	if db, ok := tester.db.(io.Closer); ok {
+		if err := db.Close(); err != nil {
+			return fmt.Errorf("Unable to close %T: %w", tester.db, err)
+		}
+	}
+	return nil
+}
+
+// Returns a new run ID made of the current time and a random suffix, such as
+// "20240102t150405_1a2b3c". Run IDs only contain lowercase letters, digits and
+// underscores, so they can be used in table names and keys.
+func NewRunID() string {
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		panic(err)
+	}
+	return fmt.Sprintf("%s_%x", strings.ToLower(time.Now().UTC().Format("20060102T150405")), suffix)
+}
+
+// Returns the name of a table for the run, the base name with the run ID as a
+// suffix, or the base name alone if there is no run ID.
+func TableName(base string, runID string) string {
+	if runID == "" {
+		return base
+	}
+	return base + "_" + runID
+}
+
+// Returns the prefix of every key of the run, the run ID followed by a colon,
+// or nothing if there is no run ID.
+func KeyPrefix(runID string) string {
+	if runID == "" {
+		return ""
+	}
+	return runID + ":"
+}
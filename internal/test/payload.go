@@ -0,0 +1,177 @@
+package test
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"unicode/utf8"
+)
+
+// What the generated text of the test data is made of.
+type PayloadContent string
+
+const (
+	ASCIIContent   PayloadContent = "ascii"   // Letters, digits and spaces.
+	UnicodeContent PayloadContent = "unicode" // A mix of one to four byte UTF-8 characters.
+	RandomContent  PayloadContent = "random"  // Base64 of random bytes, which barely compresses.
+)
+
+// The characters ASCII content is made of.
+const asciiCharacters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789 "
+
+// The characters unicode content is made of, from one to four bytes long.
+var unicodeCharacters = []rune{'a', 'é', 'ж', '€', '中', 'ü', '😀', '𝄞'}
+
+// Generates the text of the test data the tester writes.
+type Payload interface {
+	Text(random *rand.Rand) string // Returns new text with a size picked from the payload's size distribution.
+	String() string                // Describes the payload for reports.
+}
+
+// Generates text with sizes picked by a function.
+type payload struct {
+	content PayloadContent
+	size    func(random *rand.Rand) int // Picks the size of the next text in bytes.
+	name    string                      // Describes the size distribution.
+}
+
+// Creates a payload where every text is size bytes.
+func FixedPayload(size int, content PayloadContent) Payload {
+	return payload{
+		content: content,
+		size:    func(*rand.Rand) int { return size },
+		name:    fmt.Sprintf("%d bytes", size),
+	}
+}
+
+// Creates a payload with sizes spread evenly from min to max bytes.
+func UniformPayload(min int, max int, content PayloadContent) Payload {
+	return payload{
+		content: content,
+		size:    func(random *rand.Rand) int { return min + random.Intn(max-min+1) },
+		name:    fmt.Sprintf("uniform %d-%d bytes", min, max),
+	}
+}
+
+// Creates a payload with log-normally distributed sizes around the median,
+// where sigma is the standard deviation of the log of the size. Most texts are
+// close to the median with a long tail of large ones, like most real rows.
+func LognormalPayload(median int, sigma float64, content PayloadContent) Payload {
+	return payload{
+		content: content,
+		size: func(random *rand.Rand) int {
+			return max(1, int(float64(median)*math.Exp(sigma*random.NormFloat64())))
+		},
+		name: fmt.Sprintf("lognormal (median %d bytes, sigma %.2f)", median, sigma),
+	}
+}
+
+func (payload payload) Text(random *rand.Rand) string {
+	size := payload.size(random)
+	switch payload.content {
+	case UnicodeContent:
+		return unicodeText(random, size)
+	case RandomContent:
+		raw := make([]byte, base64.StdEncoding.DecodedLen(size)+3)
+		random.Read(raw)
+		return base64.StdEncoding.EncodeToString(raw)[:size]
+	}
+	text := make([]byte, size)
+	for i := range text {
+		text[i] = asciiCharacters[random.Intn(len(asciiCharacters))]
+	}
+	return string(text)
+}
+
+func (payload payload) String() string {
+	return fmt.Sprintf("%s, %s", payload.name, payload.content)
+}
+
+// Returns size bytes of random unicode characters, padded with ASCII where the
+// next character would not fit.
+func unicodeText(random *rand.Rand, size int) string {
+	var builder strings.Builder
+	builder.Grow(size)
+	for builder.Len() < size {
+		character := unicodeCharacters[random.Intn(len(unicodeCharacters))]
+		if builder.Len()+utf8.RuneLen(character) > size {
+			character = 'a'
+		}
+		builder.WriteRune(character)
+	}
+	return builder.String()
+}
+
+// Reads a payload from its short form: "fixed:SIZE", "uniform:MIN:MAX" or
+// "lognormal:MEDIAN:SIGMA", with sizes in bytes, optionally followed by the
+// content, for example "lognormal:16384:1:random". The content is ASCII unless
+// it is given. Sizes must be at least 1 byte.
+func ParsePayload(spec string) (Payload, error) {
+	content := ASCIIContent
+	for _, known := range []PayloadContent{ASCIIContent, UnicodeContent, RandomContent} {
+		if trimmed, ok := strings.CutSuffix(spec, ":"+string(known)); ok {
+			spec, content = trimmed, known
+		}
+	}
+	name, parameters, err := parseSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	if len(parameters) > 0 && parameters[0] < 1 {
+		return nil, fmt.Errorf("The sizes in %q must be at least 1 byte.", spec)
+	}
+	switch {
+	case name == "fixed" && len(parameters) == 1:
+		return FixedPayload(int(parameters[0]), content), nil
+	case name == "uniform" && len(parameters) == 2 && parameters[0] <= parameters[1]:
+		return UniformPayload(int(parameters[0]), int(parameters[1]), content), nil
+	case name == "lognormal" && len(parameters) == 2:
+		return LognormalPayload(int(parameters[0]), parameters[1], content), nil
+	}
+	return nil, fmt.Errorf("Unknown payload %q. Use fixed:SIZE, uniform:MIN:MAX or lognormal:MEDIAN:SIGMA, optionally followed by :ascii, :unicode or :random.", spec)
+}
+
+// The cost of writing and reading test data of one payload size.
+type PayloadSweep struct {
+	Size   int    // The size of the text of each test data in bytes.
+	Writes Result // The statistics of the writes.
+	Reads  Result // The statistics of the reads.
+}
+
+// Summarises the latency and bandwidth of the payload size.
+func (sweep PayloadSweep) String() string {
+	return fmt.Sprintf("payload %d bytes: writes p50 %s, p99 %s, %.2f MB/s; reads p50 %s, p99 %s, %.2f MB/s", sweep.Size,
+		sweep.Writes.P50, sweep.Writes.P99, sweep.Writes.MBPerSecond(), sweep.Reads.P50, sweep.Reads.P99, sweep.Reads.MBPerSecond())
+}
+
+// Writes and then reads back test data with texts of each size in turn, so
+// latency and bandwidth can be compared as rows grow. Each size writes and
+// reads its own test data, which is added to the tester's keys afterwards so
+// later phases, like deletes, still see it. Sizes below 1 are an error.
+func (tester dbTester) SweepPayloadSizes(ctx context.Context, sizes []int, content PayloadContent) ([]PayloadSweep, error) {
+	for _, size := range sizes {
+		if size < 1 {
+			return nil, fmt.Errorf("Payload size %d is too small. Every text needs at least 1 byte.", size)
+		}
+	}
+	sweeps := make([]PayloadSweep, 0, len(sizes))
+	for _, size := range sizes {
+		sized := tester.WithPayload(FixedPayload(size, content))
+		sized.keys = tester.keys.fork()
+		sweep := PayloadSweep{Size: size}
+		writes, err := sized.TimeWrites(ctx)
+		sweep.Writes = writes
+		if err == nil {
+			sweep.Reads, err = sized.TimeReads(ctx)
+		}
+		tester.keys.join(sized.keys)
+		if err != nil {
+			return append(sweeps, sweep), err
+		}
+		sweeps = append(sweeps, sweep)
+	}
+	return sweeps, nil
+}
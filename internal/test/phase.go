@@ -0,0 +1,55 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// The names of the phases that can be run by name.
+const (
+	PhaseWrites   = "writes"
+	PhaseReads    = "reads"
+	PhaseUpdates  = "updates"
+	PhaseScans    = "scans"
+	PhaseDeletes  = "deletes"
+	PhaseCounters = "counters"
+	PhaseYCSB     = "ycsb-" // Followed by the letter of a YCSB core workload, for example "ycsb-a".
+)
+
+// The number of hot counters the counters phase contends on when it is run by name.
+const phaseCountersDefault = 5
+
+// Lists the names of the phases that can be run by name.
+func Phases() []string {
+	phases := []string{PhaseWrites, PhaseReads, PhaseUpdates, PhaseScans, PhaseDeletes, PhaseCounters}
+	for _, letter := range []string{"a", "b", "c", "d", "e", "f"} {
+		phases = append(phases, PhaseYCSB+letter)
+	}
+	return phases
+}
+
+// Runs the phase with the given name, one of the Phase constants. Unknown
+// phases return an error without running anything.
+func (tester dbTester) RunPhase(ctx context.Context, phase string) (Result, error) {
+	switch phase {
+	case PhaseWrites:
+		return tester.TimeWrites(ctx)
+	case PhaseReads:
+		return tester.TimeReads(ctx)
+	case PhaseUpdates:
+		return tester.TimeUpdates(ctx)
+	case PhaseScans:
+		return tester.TimeScans(ctx)
+	case PhaseDeletes:
+		return tester.TimeDeletes(ctx)
+	case PhaseCounters:
+		return tester.TimeCounters(ctx, phaseCountersDefault)
+	}
+	if letter, ok := strings.CutPrefix(phase, PhaseYCSB); ok {
+		if workload, ok := YCSBWorkload(letter); ok {
+			return tester.TimeMixed(ctx, workload)
+		}
+	}
+	return newResult(NewHistogram()), fmt.Errorf("Unknown phase %q. The phases are %s.", phase, strings.Join(Phases(), ", "))
+}
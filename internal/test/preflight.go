@@ -0,0 +1,87 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// The deadline for preflight checks when the tester has no operation timeout.
+const preflightTimeoutDefault = 30 * time.Second
+
+// A test database that can check it is configured and reachable before
+// anything is timed.
+type Preflighter interface {
+	Preflight(context.Context) error // Checks connectivity and auth, returning an error that says what to fix.
+}
+
+// Checks the test database is reachable and accepts its credentials, if it
+// implements Preflighter. Commands run it for every test database before any
+// of them is set up, so a bad config fails fast instead of partway through a
+// run.
+func (tester dbTester) Preflight(ctx context.Context) error {
+	db, ok := tester.db.(Preflighter)
+	if !ok {
+		return nil
+	}
+	ctx, cancel := tester.preflightContext(ctx)
+	defer cancel()
+	if err := db.Preflight(ctx); err != nil {
+		return fmt.Errorf("Preflight of %T failed: %w", tester.db, err)
+	}
+	return nil
+}
+
+// Confirms the schema the test database set up exists by reading a key that is
+// never written, which must come back as ErrNotFound. A missing table or a
+// mistyped column fails here instead of in every timed operation. Run checks
+// the schema after setup, and so should callers that set up test databases
+// themselves.
+func (tester dbTester) CheckSchema(ctx context.Context) error {
+	ctx, cancel := tester.preflightContext(ctx)
+	defer cancel()
+	_, err := withContext(tester.db).ReadTestDataContext(ctx, -1)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("The schema of %T is not ready, reading test data failed: %w", tester.db, err)
+	}
+	return nil
+}
+
+// Returns the context preflight checks run with, which has the tester's
+// operation timeout, or 30 seconds without one.
+func (tester dbTester) preflightContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if tester.opTimeout > 0 {
+		return context.WithTimeout(ctx, tester.opTimeout)
+	}
+	return context.WithTimeout(ctx, preflightTimeoutDefault)
+}
+
+// Returns the values of the environment variables, in order, or an error that
+// names every one that is not set.
+func RequireEnv(names ...string) ([]string, error) {
+	values := make([]string, len(names))
+	var missing []string
+	for i, name := range names {
+		values[i] = os.Getenv(name)
+		if values[i] == "" {
+			missing = append(missing, name)
+		}
+	}
+	switch len(missing) {
+	case 0:
+		return values, nil
+	case 1:
+		return values, fmt.Errorf("%s is not set. Set it in the environment or in a .env file in the working directory.", missing[0])
+	}
+	return values, fmt.Errorf("%s are not set. Set them in the environment or in a .env file in the working directory.", strings.Join(missing, ", "))
+}
+
+// Returns whether the error is an HTTP response saying the credentials were
+// missing or rejected.
+func IsAuthError(err error) bool {
+	var statusErr HTTPStatusError
+	return errors.As(err, &statusErr) && (statusErr.StatusCode == 401 || statusErr.StatusCode == 403)
+}
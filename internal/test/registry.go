@@ -0,0 +1,69 @@
+package test
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// A backend the benchmark can run against. Adapter packages register
+// themselves in init, so commands only need to import them to use them.
+type Adapter struct {
+	Name        string                                                        // The name the adapter is picked by, for example "turso".
+	Description string                                                        // A one-line description for listings.
+	Env         []string                                                      // The environment variables Open reads.
+	Open        func(ctx context.Context, runID string) (TestDatabase, error) // Connects to the backend, namespacing the run's data with the run ID.
+	Pause       time.Duration                                                 // The pause between wait groups the backend needs, for example to stay under a rate limit.
+	Warmup      int                                                           // The operations to run before each phase, for example to set up connections.
+	OpTimeout   time.Duration                                                 // The deadline for each operation. Zero means no deadline.
+	Flags       func(flags *flag.FlagSet)                                     // Defines the adapter's own options as flags named "<name>.<option>", for Open to read. Optional.
+}
+
+var registry = struct {
+	mutex    sync.Mutex
+	adapters map[string]Adapter
+}{adapters: make(map[string]Adapter)}
+
+// Registers the adapter under its name. Registering two adapters with the same
+// name panics, since it is a programming error.
+func Register(adapter Adapter) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	if _, ok := registry.adapters[adapter.Name]; ok {
+		panic(fmt.Sprintf("test: adapter %q is registered twice", adapter.Name))
+	}
+	registry.adapters[adapter.Name] = adapter
+}
+
+// Returns the adapter registered under the name.
+func LookupAdapter(name string) (Adapter, bool) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	adapter, ok := registry.adapters[name]
+	return adapter, ok
+}
+
+// Returns every registered adapter, sorted by name.
+func Adapters() []Adapter {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	adapters := make([]Adapter, 0, len(registry.adapters))
+	for _, adapter := range registry.adapters {
+		adapters = append(adapters, adapter)
+	}
+	sort.Slice(adapters, func(i, j int) bool { return adapters[i].Name < adapters[j].Name })
+	return adapters
+}
+
+// Creates a tester for a test database opened by the adapter, with the
+// adapter's pause, warmup and operation timeout.
+func (adapter Adapter) Tester(db TestDatabase) dbTester {
+	tester := NewDbTester(db).WithPause(adapter.Pause).WithOpTimeout(adapter.OpTimeout)
+	if adapter.Warmup > 0 {
+		tester = tester.WithWarmup(adapter.Warmup)
+	}
+	return tester
+}
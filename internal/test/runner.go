@@ -0,0 +1,387 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// The ways a tester can schedule operations against the test database.
+const (
+	ModeBatch = "batch" // Sends wait groups of concurrent operations and waits for each group to finish.
+	ModePool  = "pool"  // Keeps a fixed number of workers busy pulling operations from a shared queue.
+	ModeRate  = "rate"  // Sends operations at a constant rate no matter how fast they finish.
+)
+
+// A single operation against the test database for the given key.
+type operation func(ctx context.Context, key int64) (outcome, error)
+
+// Prepares the operation for a key before it is timed, for example by building
+// the test data it writes, so the preparation is not part of its latency.
+type preparer func(key int64) operation
+
+// What a single operation did, beyond how long it took.
+type outcome struct {
+	kind  string // The operation type, so it gets its own statistics. Empty if the phase has one type.
+	rows  int    // The number of records the operation read or wrote, if it handles more than one.
+	bytes int    // The number of text bytes the operation read or wrote.
+}
+
+// Collects the outcome of every operation in a timed run.
+type recorder struct {
+	mutex  sync.Mutex
+	times  *Histogram     // The latencies of the successful operations.
+	failed int            // The number of failed operations.
+	errors map[string]int // The number of failed operations by error class.
+	rows   int64          // The number of records the successful operations handled.
+	bytes  int64          // The number of text bytes the successful operations read or wrote.
+
+	operations map[string]*recorder // The outcomes broken down by operation type.
+}
+
+func newRecorder() *recorder {
+	return &recorder{times: NewHistogram(), errors: make(map[string]int), operations: make(map[string]*recorder)}
+}
+
+// Records the latency of a successful operation or the class of a failed one,
+// both overall and for the operation type if there is one.
+func (rec *recorder) record(out outcome, duration time.Duration, err error) {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+	if out.kind != "" {
+		operation, ok := rec.operations[out.kind]
+		if !ok {
+			operation = newRecorder()
+			rec.operations[out.kind] = operation
+		}
+		operation.record(outcome{rows: out.rows, bytes: out.bytes}, duration, err)
+	}
+	if err != nil {
+		rec.failed++
+		rec.errors[ClassifyError(err)]++
+		return
+	}
+	rec.times.Record(duration)
+	rec.rows += int64(out.rows)
+	rec.bytes += int64(out.bytes)
+}
+
+// Whether more than the maximum error rate of the operations so far have
+// failed. Nothing is reported until enough operations have finished for the
+// rate to mean something.
+func (rec *recorder) exceeds(maxErrorRate float64) bool {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+	attempted := rec.failed + int(rec.times.Count)
+	if attempted < testErrorRateMinimumOps {
+		return false
+	}
+	return float64(rec.failed)/float64(attempted) > maxErrorRate
+}
+
+// Summarizes everything recorded so far.
+func (rec *recorder) result() Result {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+	result := newResult(rec.times.Copy())
+	result.Failed = rec.failed
+	result.Rows = rec.rows
+	result.Bytes = rec.bytes
+	for class, count := range rec.errors {
+		result.Errors[class] = count
+	}
+	if len(rec.operations) > 0 {
+		result.Operations = make(map[string]Result)
+		for kind, operation := range rec.operations {
+			result.Operations[kind] = operation.result()
+		}
+	}
+	return result
+}
+
+// A single timed run of one operation over the key space.
+type run struct {
+	tester  dbTester
+	action  string    // What the operation does, used for logging.
+	prepare preparer  // Prepares the operation to time for each key.
+	rec     *recorder // The outcome of every finished operation.
+}
+
+// Runs the prepared operation for a key and records the outcome. The latency is measured
+// from the given start, which is the intended send time in rate mode so that
+// queueing delay is part of it. Operations that fail because the run was
+// canceled are left out.
+func (r *run) execute(ctx context.Context, key int64, op operation, start time.Time) {
+	if r.tester.verbose {
+		fmt.Printf("Started %s %d.\n", r.action, key)
+	}
+	opCtx, cancel := r.tester.operationContext(ctx)
+	defer cancel()
+	out, err := op(opCtx, key)
+	duration := time.Since(start)
+	if err != nil && ctx.Err() != nil {
+		return
+	}
+	r.rec.record(out, duration, err)
+	if err != nil {
+		if r.tester.verbose {
+			fmt.Fprintf(os.Stderr, "Failed %s %d after %s: %s\n", r.action, key, duration, err)
+		}
+		return
+	}
+	if r.tester.verbose {
+		fmt.Printf("Finished %s %d in %s.\n", r.action, key, duration)
+	}
+}
+
+// Runs the operation for the tester's total or duration using the tester's
+// mode and times each call. The key function picks the key for each operation
+// and can end the run early by running out of keys. Failed operations are
+// counted by error class, and the run stops early with ErrAborted once the
+// maximum error rate is exceeded. Canceling the context stops the run and
+// cancels the operations in flight, which are then left out of the result.
+//
+// If the tester has a warmup, the warmup operations run first in the same way
+// and are reported separately so they do not skew the statistics.
+func (tester dbTester) timeOperations(ctx context.Context, action string, key keyFunc, op operation) (Result, error) {
+	return tester.timePrepared(ctx, action, key, func(int64) operation { return op })
+}
+
+// Runs the operations from the preparer like timeOperations. Each operation is
+// prepared before its clock starts.
+func (tester dbTester) timePrepared(ctx context.Context, action string, key keyFunc, prepare preparer) (Result, error) {
+	if offset := tester.offset; offset > 0 {
+		from := key
+		key = func(n int) (int64, bool) { return from(n + offset) }
+	}
+	if tester.warmup == 0 && tester.warmupDuration == 0 {
+		return tester.timeSource(ctx, action, newKeySource(tester.total, tester.duration, key), prepare)
+	}
+
+	warmupSource := newKeySource(tester.warmup, tester.warmupDuration, key)
+	warmup, err := tester.timeSource(ctx, "warming up by "+action, warmupSource, prepare)
+	if err != nil {
+		result := newResult(NewHistogram())
+		result.Mode = warmup.Mode
+		result.Warmup = &warmup
+		return result, err
+	}
+	result, err := tester.timeSource(ctx, action, warmupSource.after(tester.total, tester.duration), prepare)
+	result.Warmup = &warmup
+	return result, err
+}
+
+// Runs the operation for every key from the key source and times each call.
+func (tester dbTester) timeSource(ctx context.Context, action string, source *keySource, prepare preparer) (Result, error) {
+	r := &run{tester: tester, action: action, prepare: prepare, rec: newRecorder()}
+	var elapsed, paused time.Duration
+	var err error
+	switch tester.mode {
+	case ModePool:
+		elapsed, err = r.inPool(ctx, source)
+	case ModeRate:
+		elapsed, err = r.atRate(ctx, source)
+	default:
+		elapsed, paused, err = r.inBatches(ctx, source)
+	}
+
+	result := r.rec.result()
+	result.RunID = tester.runID
+	result.Mode = tester.describeMode()
+	if tester.payload != nil {
+		result.Payload = tester.payload.String()
+	}
+	result.Elapsed = elapsed
+	result.Paused = paused
+	if tester.verbose {
+		fmt.Printf("Done %s %d records.\n", action, result.Count+result.Failed)
+	}
+	return result, err
+}
+
+// Sends the operations in wait groups, waiting for every operation in a group
+// to finish and pausing before the next group is sent. Returns the elapsed
+// wall-clock time up to the end of the last group and the time spent pausing
+// between groups.
+func (r *run) inBatches(ctx context.Context, source *keySource) (time.Duration, time.Duration, error) {
+	waitGroup, pause := r.tester.waitGroup, r.tester.pause
+
+	var wg sync.WaitGroup
+	var elapsed, paused, pending time.Duration
+	var runErr error
+	start := time.Now()
+	for sent := 0; runErr == nil; {
+		launched := 0
+		for ; launched < waitGroup; launched++ {
+			key, ok := source.next()
+			if !ok {
+				break
+			}
+			op := r.prepare(key)
+			wg.Add(1)
+			go func(key int64) {
+				defer wg.Done()
+				r.execute(ctx, key, op, time.Now())
+			}(key)
+		}
+		if launched == 0 {
+			break
+		}
+		// A pause only counts once another group follows it. The pause after the
+		// last group just spaces out the next phase.
+		paused += pending
+		sent += launched
+		wg.Wait()
+		elapsed = time.Since(start)
+		if ctx.Err() != nil {
+			runErr = ctx.Err()
+		} else if r.rec.exceeds(r.tester.maxErrorRate) {
+			runErr = ErrAborted
+		}
+		if runErr != nil || source.done() {
+			break
+		}
+		if r.tester.verbose {
+			fmt.Printf("Finished %s %d records.\n", r.action, sent)
+			fmt.Printf("Waiting for %s.\n", pause)
+		}
+		if pause > 0 {
+			pauseStart := time.Now()
+			runErr = sleep(ctx, pause)
+			pending = time.Since(pauseStart)
+		}
+	}
+	return elapsed, paused, runErr
+}
+
+// Sends the operations through a pool of wait group sized workers. Each worker
+// takes the next key as soon as its previous operation finishes, so the number
+// of operations in flight stays constant and a slow operation only holds up its
+// own worker. Returns the elapsed wall-clock time.
+func (r *run) inPool(ctx context.Context, source *keySource) (time.Duration, error) {
+	type task struct {
+		key int64
+		op  operation
+	}
+	tasks := make(chan task)
+
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < r.tester.waitGroup; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for task := range tasks {
+				r.execute(ctx, task.key, task.op, time.Now())
+			}
+		}()
+	}
+
+	var runErr error
+	for runErr == nil {
+		if r.rec.exceeds(r.tester.maxErrorRate) {
+			runErr = ErrAborted
+			break
+		}
+		key, ok := source.next()
+		if !ok {
+			break
+		}
+		select {
+		case tasks <- task{key: key, op: r.prepare(key)}:
+		case <-ctx.Done():
+			runErr = ctx.Err()
+		}
+	}
+	close(tasks)
+	wg.Wait()
+	if runErr == nil {
+		runErr = ctx.Err()
+	}
+	return time.Since(start), runErr
+}
+
+// Sends the operations at the tester's rate without waiting for earlier
+// operations to finish. Each operation has an intended send time on a fixed
+// schedule, and its latency is measured from that time rather than from when
+// it was actually sent, which corrects for coordinated omission. Operations are
+// prepared before their intended send time so the preparation does not count
+// as queueing delay. Returns the elapsed wall-clock time. A rate of zero or
+// less is an error and sends nothing.
+func (r *run) atRate(ctx context.Context, source *keySource) (time.Duration, error) {
+	if !(r.tester.rate > 0) {
+		return 0, fmt.Errorf("Rate mode needs a rate above zero, not %v.", r.tester.rate)
+	}
+	interval := float64(time.Second) / r.tester.rate
+
+	var wg sync.WaitGroup
+	var runErr error
+	start := time.Now()
+	for i := 0; ; i++ {
+		intended := start.Add(time.Duration(float64(i) * interval))
+		key, ok := source.next()
+		if !ok {
+			break
+		}
+		op := r.prepare(key)
+		if runErr = sleep(ctx, time.Until(intended)); runErr != nil {
+			break
+		}
+		if r.rec.exceeds(r.tester.maxErrorRate) {
+			runErr = ErrAborted
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.execute(ctx, key, op, intended)
+		}()
+	}
+	wg.Wait()
+	if runErr == nil {
+		runErr = ctx.Err()
+	}
+	return time.Since(start), runErr
+}
+
+// Describes how the tester schedules operations and how long it runs, for reports.
+func (tester dbTester) describeMode() string {
+	var mode string
+	switch tester.mode {
+	case ModePool:
+		mode = fmt.Sprintf("%s (%d workers)", ModePool, tester.waitGroup)
+	case ModeRate:
+		mode = fmt.Sprintf("%s (%.2f ops/s target)", ModeRate, tester.rate)
+	default:
+		mode = fmt.Sprintf("%s (wait groups of %d, %s pause)", ModeBatch, tester.waitGroup, tester.pause)
+	}
+	if tester.duration > 0 {
+		return fmt.Sprintf("%s for %s", mode, tester.duration)
+	}
+	return mode
+}
+
+// Creates the context for a single operation, applying the per-operation deadline.
+func (tester dbTester) operationContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if tester.opTimeout > 0 {
+		return context.WithTimeout(ctx, tester.opTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// Sleeps for the duration or until the context is canceled.
+func sleep(ctx context.Context, duration time.Duration) error {
+	if duration <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,196 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A test database that takes a while to write, can fail writes and keeps
+// track of how many writes are in flight.
+type slowDatabase struct {
+	memoryDatabase
+	delay func(key int64) time.Duration // How long the write of the key takes.
+	err   error                         // Returned by every write if set.
+
+	mutex       sync.Mutex
+	inFlight    int
+	maxInFlight int
+}
+
+func (db *slowDatabase) WriteTestDataContext(ctx context.Context, data TestData) error {
+	db.mutex.Lock()
+	db.inFlight++
+	db.maxInFlight = max(db.maxInFlight, db.inFlight)
+	db.mutex.Unlock()
+	defer func() {
+		db.mutex.Lock()
+		db.inFlight--
+		db.mutex.Unlock()
+	}()
+	if db.delay != nil {
+		if err := sleep(ctx, db.delay(data.Key)); err != nil {
+			return err
+		}
+	}
+	if db.err != nil {
+		return db.err
+	}
+	return db.WriteTestData(data)
+}
+
+func (db *slowDatabase) ReadTestDataContext(_ context.Context, key int64) (*TestData, error) {
+	return db.ReadTestData(key)
+}
+
+// Takes the same time for every key.
+func constantDelay(delay time.Duration) func(int64) time.Duration {
+	return func(int64) time.Duration { return delay }
+}
+
+// The tester in each mode, for behaviour every mode shares.
+var runnerModes = []struct {
+	name   string
+	tester func() dbTester
+}{
+	{ModeBatch, func() dbTester { return NewDbTester(nil).WithWaitGroup(10) }},
+	{ModePool, func() dbTester { return NewDbTester(nil).WithWorkers(10) }},
+	{ModeRate, func() dbTester { return NewDbTester(nil).WithRate(2000) }},
+}
+
+func TestRateModeRejectsRatesNotAboveZero(t *testing.T) {
+	for _, rate := range []float64{0, -5} {
+		db := &memoryDatabase{}
+		if _, err := NewDbTester(db).WithTotal(10).WithRate(rate).TimeWrites(context.Background()); err == nil {
+			t.Errorf("rate %v: no error", rate)
+		}
+		if written := db.len(); written != 0 {
+			t.Errorf("rate %v: wrote %d test data, want 0", rate, written)
+		}
+	}
+}
+
+func TestRateModeMeasuresFromTheIntendedSendTime(t *testing.T) {
+	// The first operation takes long to prepare, so every operation is sent
+	// late. The writes themselves are instant, so the latency is all queueing.
+	const late = 50 * time.Millisecond
+	tester := NewDbTester(nil).WithTotal(5).WithRate(1000).forDatabase(&memoryDatabase{})
+	result, err := tester.timePrepared(context.Background(), "writing", tester.writeKeys(), func(key int64) operation {
+		if key == 1 {
+			time.Sleep(late)
+		}
+		return func(context.Context, int64) (outcome, error) { return outcome{}, nil }
+	})
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if result.Count != 5 {
+		t.Fatalf("timed %d operations, want 5", result.Count)
+	}
+	// The last operation was intended 4ms after the first, so it is at least
+	// late less 4ms behind.
+	if result.Min < late-5*time.Millisecond {
+		t.Errorf("fastest operation %s, want at least %s of queueing delay", result.Min, late-5*time.Millisecond)
+	}
+}
+
+func TestPoolModeKeepsToItsWorkers(t *testing.T) {
+	for _, workers := range []int{1, 4, 16} {
+		db := &slowDatabase{delay: constantDelay(time.Millisecond)}
+		result, err := NewDbTester(db).WithTotal(100).WithWorkers(workers).TimeWrites(context.Background())
+		if err != nil {
+			t.Fatalf("%d workers: run failed: %v", workers, err)
+		}
+		if result.Count != 100 {
+			t.Errorf("%d workers: timed %d operations, want 100", workers, result.Count)
+		}
+		if db.maxInFlight > workers {
+			t.Errorf("%d workers: %d operations in flight", workers, db.maxInFlight)
+		}
+	}
+}
+
+func TestRunsAbortAboveTheMaxErrorRate(t *testing.T) {
+	for _, mode := range runnerModes {
+		db := &slowDatabase{err: errors.New("failed")}
+		result, err := mode.tester().WithTotal(1000).WithMaxErrorRate(0.1).forDatabase(db).TimeWrites(context.Background())
+		if !errors.Is(err, ErrAborted) {
+			t.Errorf("%s: error %v, want %v", mode.name, err, ErrAborted)
+		}
+		if attempted := result.Count + result.Failed; attempted == 0 || attempted >= 1000 {
+			t.Errorf("%s: attempted %d operations, want the run to stop early", mode.name, attempted)
+		}
+		if result.Errors[ErrorClassDriver] != result.Failed {
+			t.Errorf("%s: errors %v, want %d driver errors", mode.name, result.Errors, result.Failed)
+		}
+	}
+}
+
+func TestCanceledOperationsAreLeftOut(t *testing.T) {
+	for _, mode := range runnerModes {
+		db := &slowDatabase{delay: constantDelay(time.Hour)}
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		result, err := mode.tester().WithTotal(20).forDatabase(db).TimeWrites(ctx)
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%s: error %v, want %v", mode.name, err, context.DeadlineExceeded)
+		}
+		if result.Count != 0 || result.Failed != 0 || len(result.Errors) != 0 {
+			t.Errorf("%s: timed %d and failed %d operations with errors %v, want none", mode.name, result.Count, result.Failed, result.Errors)
+		}
+	}
+}
+
+func TestDurationRunsStopOnTime(t *testing.T) {
+	const duration = 100 * time.Millisecond
+	for _, mode := range runnerModes {
+		db := &slowDatabase{delay: constantDelay(time.Millisecond)}
+		tester := mode.tester().WithTotal(5).WithDuration(duration).forDatabase(db)
+		start := time.Now()
+		result, err := tester.TimeWrites(context.Background())
+		took := time.Since(start)
+		if err != nil {
+			t.Fatalf("%s: run failed: %v", mode.name, err)
+		}
+		if took < duration || took > duration+100*time.Millisecond {
+			t.Errorf("%s: ran for %s, want about %s", mode.name, took, duration)
+		}
+		// The total is ignored, so the writes keep claiming new keys.
+		if result.Count <= 5 {
+			t.Errorf("%s: timed %d operations, want more than the total of 5", mode.name, result.Count)
+		}
+		if written := len(tester.keys.snapshot()); written != result.Count || db.len() != written {
+			t.Errorf("%s: %d keys written and %d stored, want %d", mode.name, written, db.len(), result.Count)
+		}
+	}
+}
+
+func TestWarmupIsReportedSeparately(t *testing.T) {
+	// The warmup writes the first keys, which are slow, so they would show up
+	// in the timed statistics if they were mixed in.
+	const slow = 30 * time.Millisecond
+	db := &slowDatabase{delay: func(key int64) time.Duration {
+		if key <= 7 {
+			return slow
+		}
+		return 0
+	}}
+	result, err := NewDbTester(db).WithTotal(20).WithWaitGroup(10).WithWarmup(7).TimeWrites(context.Background())
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if result.Warmup == nil || result.Warmup.Count != 7 {
+		t.Fatalf("warmup %+v, want 7 operations", result.Warmup)
+	}
+	if result.Count != 20 || db.len() != 27 {
+		t.Errorf("timed %d operations and stored %d test data, want 20 and 27", result.Count, db.len())
+	}
+	if result.Warmup.Min < slow-time.Millisecond {
+		t.Errorf("fastest warmup operation %s, want at least %s", result.Warmup.Min, slow)
+	}
+	if result.Max >= slow-time.Millisecond {
+		t.Errorf("slowest timed operation %s, want the warmup left out", result.Max)
+	}
+}
@@ -0,0 +1,64 @@
+package test
+
+import (
+	"context"
+	"fmt"
+)
+
+// A test database that can read a page of test data by key range.
+type Scanner interface {
+	ScanTestData(ctx context.Context, from int64, to int64, limit int) ([]TestData, error) // Returns up to limit test data with keys from `from` to `to` inclusive, in key order.
+}
+
+// Pages through the written test data and returns the latency statistics of
+// each page fetch. Each scan starts at a written key and fetches up to the
+// tester's page size of test data with keys from there on. The pages are picked
+// with the tester's key distribution, in order by default, and the result
+// reports the rows fetched per second. The error wraps ErrUnsupported if the
+// test database cannot scan, and is ErrNoKeys if nothing has been written yet.
+func (tester dbTester) TimeScans(ctx context.Context) (Result, error) {
+	scanner, ok := tester.db.(Scanner)
+	if !ok {
+		return newResult(NewHistogram()), fmt.Errorf("%T does not implement test.Scanner: %w", tester.db, ErrUnsupported)
+	}
+	written := tester.keys.snapshot()
+	if len(written) == 0 {
+		return newResult(NewHistogram()), ErrNoKeys
+	}
+
+	// Every page starts at a key a whole number of pages into the written keys.
+	pageSize := tester.pageSize
+	if pageSize < 1 {
+		pageSize = 1
+	}
+	pageStarts := make([]int64, 0, len(written)/pageSize+1)
+	for i := 0; i < len(written); i += pageSize {
+		pageStarts = append(pageStarts, written[i])
+	}
+	distribution := tester.keyDistributionOr(SequentialKeys())
+	random := newLockedRand(tester.seed)
+	pages := func(n int) (int64, bool) {
+		return pageStarts[random.index(distribution, n, len(pageStarts))], true
+	}
+	result, err := tester.timeOperations(ctx, "scanning", pages, func(ctx context.Context, from int64) (outcome, error) {
+		return tester.scan(ctx, scanner, from)
+	})
+	result.Keys = tester.describeKeys(distribution)
+	return result, err
+}
+
+// Fetches a page of test data starting at the key.
+func (tester dbTester) scan(ctx context.Context, db Scanner, from int64) (outcome, error) {
+	page, err := db.ScanTestData(ctx, from, tester.keys.newest(), tester.pageSize)
+	if err != nil {
+		return outcome{}, err
+	}
+	if len(page) > tester.pageSize {
+		return outcome{}, fmt.Errorf("Scan from key %d returned %d test data but the limit was %d.", from, len(page), tester.pageSize)
+	}
+	out := outcome{rows: len(page)}
+	for _, data := range page {
+		out.bytes += len(data.Text)
+	}
+	return out, nil
+}
@@ -0,0 +1,215 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// A duration that is written as a string like "10s" or "1m30s" in JSON. Plain
+// numbers are read as nanoseconds.
+type Duration time.Duration
+
+func (duration Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(duration).String())
+}
+
+func (duration *Duration) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		var nanoseconds int64
+		if err := json.Unmarshal(data, &nanoseconds); err != nil {
+			return fmt.Errorf("A duration must be a string like \"10s\" or a number of nanoseconds, not %s.", data)
+		}
+		*duration = Duration(nanoseconds)
+		return nil
+	}
+	parsed, err := time.ParseDuration(text)
+	if err != nil {
+		return err
+	}
+	*duration = Duration(parsed)
+	return nil
+}
+
+// Tester settings as they are written in scenario files. Zero values and nil
+// pointers leave the tester's setting as it is, so settings can be layered.
+type Settings struct {
+	Total           int       `json:"total,omitempty"`           // The number of operations per phase.
+	Duration        Duration  `json:"duration,omitempty"`        // How long each phase runs instead of a total.
+	Mode            string    `json:"mode,omitempty"`            // How operations are scheduled: "batch", "pool" or "rate".
+	Concurrency     int       `json:"concurrency,omitempty"`     // The wait group size in batch mode or the number of workers in pool mode.
+	Rate            float64   `json:"rate,omitempty"`            // The operations per second in rate mode. A rate without a mode means rate mode.
+	Pause           *Duration `json:"pause,omitempty"`           // The pause between wait groups.
+	Warmup          *int      `json:"warmup,omitempty"`          // The number of warmup operations before each phase.
+	WarmupDuration  *Duration `json:"warmupDuration,omitempty"`  // How long to warm up before each phase instead of a number of operations.
+	OpTimeout       *Duration `json:"opTimeout,omitempty"`       // The deadline for each operation.
+	MaxErrorRate    *float64  `json:"maxErrorRate,omitempty"`    // The fraction of failed operations that aborts a phase.
+	Seed            *int64    `json:"seed,omitempty"`            // The seed for every random choice.
+	Keys            string    `json:"keys,omitempty"`            // The key distribution, as read by ParseKeyDistribution.
+	Verify          *bool     `json:"verify,omitempty"`          // Whether reads are compared with what was written.
+	VerifyPrecision *Duration `json:"verifyPrecision,omitempty"` // The precision timestamps are compared at when verifying.
+	PageSize        int       `json:"pageSize,omitempty"`        // The number of test data per scan.
+	Payload         string    `json:"payload,omitempty"`         // The payload of written test data, as read by ParsePayload.
+	MaxVariation    *float64  `json:"maxVariation,omitempty"`    // The coefficient of variation above which trials are unstable.
+	Verbose         *bool     `json:"verbose,omitempty"`         // Whether to log extra info.
+	Repetitions     int       `json:"repetitions,omitempty"`     // The number of trials to run each phase as. Not a tester setting, it is up to whoever runs the phases.
+}
+
+// Returns the settings with every setting that is set in other replacing its own.
+func (settings Settings) Override(other Settings) Settings {
+	if other.Total != 0 {
+		settings.Total, settings.Duration = other.Total, 0
+	}
+	if other.Duration != 0 {
+		settings.Duration = other.Duration
+	}
+	if other.Mode != "" {
+		settings.Mode = other.Mode
+	}
+	if other.Concurrency != 0 {
+		settings.Concurrency = other.Concurrency
+	}
+	if other.Rate != 0 {
+		settings.Rate = other.Rate
+	}
+	if other.Pause != nil {
+		settings.Pause = other.Pause
+	}
+	if other.Warmup != nil {
+		settings.Warmup, settings.WarmupDuration = other.Warmup, nil
+	}
+	if other.WarmupDuration != nil {
+		settings.WarmupDuration = other.WarmupDuration
+	}
+	if other.OpTimeout != nil {
+		settings.OpTimeout = other.OpTimeout
+	}
+	if other.MaxErrorRate != nil {
+		settings.MaxErrorRate = other.MaxErrorRate
+	}
+	if other.Seed != nil {
+		settings.Seed = other.Seed
+	}
+	if other.Keys != "" {
+		settings.Keys = other.Keys
+	}
+	if other.Verify != nil {
+		settings.Verify = other.Verify
+	}
+	if other.VerifyPrecision != nil {
+		settings.VerifyPrecision = other.VerifyPrecision
+	}
+	if other.PageSize != 0 {
+		settings.PageSize = other.PageSize
+	}
+	if other.Payload != "" {
+		settings.Payload = other.Payload
+	}
+	if other.MaxVariation != nil {
+		settings.MaxVariation = other.MaxVariation
+	}
+	if other.Verbose != nil {
+		settings.Verbose = other.Verbose
+	}
+	if other.Repetitions != 0 {
+		settings.Repetitions = other.Repetitions
+	}
+	return settings
+}
+
+// Applies every setting that is set to the tester. The error says which
+// setting is invalid, if any is.
+func (tester dbTester) WithSettings(settings Settings) (dbTester, error) {
+	// Zero leaves a count as it is, so only negative counts are below 1.
+	for _, count := range []struct {
+		name  string
+		value int
+	}{{"total", settings.Total}, {"concurrency", settings.Concurrency}, {"page size", settings.PageSize}} {
+		if count.value < 0 {
+			return tester, fmt.Errorf("The %s must be at least 1, not %d.", count.name, count.value)
+		}
+	}
+	if settings.Total != 0 {
+		tester = tester.WithTotal(settings.Total)
+	}
+	if settings.Duration != 0 {
+		tester = tester.WithDuration(time.Duration(settings.Duration))
+	}
+	if settings.Rate != 0 {
+		tester.rate = settings.Rate
+	}
+	if settings.Concurrency != 0 {
+		tester = tester.WithWaitGroup(settings.Concurrency)
+	}
+	mode := settings.Mode
+	if settings.Rate != 0 {
+		if mode == "" {
+			mode = ModeRate
+		} else if mode != ModeRate {
+			return tester, fmt.Errorf("A rate only applies in rate mode, not %s mode. Leave out the mode or set it to %s.", mode, ModeRate)
+		}
+	}
+	switch mode {
+	case "":
+	case ModeBatch:
+		tester = tester.WithBatches()
+	case ModePool:
+		tester = tester.WithWorkers(tester.waitGroup)
+	case ModeRate:
+		if tester.rate <= 0 {
+			return tester, fmt.Errorf("Rate mode needs a rate above zero.")
+		}
+		tester = tester.WithRate(tester.rate)
+	default:
+		return tester, fmt.Errorf("Unknown mode %q. The modes are %s, %s and %s.", settings.Mode, ModeBatch, ModePool, ModeRate)
+	}
+	if settings.Pause != nil {
+		tester = tester.WithPause(time.Duration(*settings.Pause))
+	}
+	if settings.Warmup != nil {
+		tester = tester.WithWarmup(*settings.Warmup)
+	}
+	if settings.WarmupDuration != nil {
+		tester = tester.WithWarmupDuration(time.Duration(*settings.WarmupDuration))
+	}
+	if settings.OpTimeout != nil {
+		tester = tester.WithOpTimeout(time.Duration(*settings.OpTimeout))
+	}
+	if settings.MaxErrorRate != nil {
+		tester = tester.WithMaxErrorRate(*settings.MaxErrorRate)
+	}
+	if settings.Seed != nil {
+		tester = tester.WithSeed(*settings.Seed)
+	}
+	if settings.Keys != "" {
+		distribution, err := ParseKeyDistribution(settings.Keys)
+		if err != nil {
+			return tester, err
+		}
+		tester = tester.WithKeyDistribution(distribution)
+	}
+	if settings.VerifyPrecision != nil {
+		tester.verifyPrecision = time.Duration(*settings.VerifyPrecision)
+	}
+	if settings.Verify != nil {
+		tester.verify = *settings.Verify
+	}
+	if settings.PageSize != 0 {
+		tester = tester.WithPageSize(settings.PageSize)
+	}
+	if settings.Payload != "" {
+		payload, err := ParsePayload(settings.Payload)
+		if err != nil {
+			return tester, err
+		}
+		tester = tester.WithPayload(payload)
+	}
+	if settings.MaxVariation != nil {
+		tester = tester.WithMaxVariation(*settings.MaxVariation)
+	}
+	if settings.Verbose != nil {
+		tester.verbose = *settings.Verbose
+	}
+	return tester, nil
+}
@@ -0,0 +1,50 @@
+package test
+
+import "testing"
+
+func TestWithSettingsRejectsCountsBelowOne(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings Settings
+	}{
+		{"total", Settings{Total: -5}},
+		{"concurrency", Settings{Mode: ModePool, Concurrency: -1}},
+		{"page size", Settings{PageSize: -1}},
+		{"payload", Settings{Payload: "fixed:-1"}},
+	}
+	for _, test := range tests {
+		if _, err := NewDbTester(nil).WithSettings(test.settings); err == nil {
+			t.Errorf("%s: %+v was accepted", test.name, test.settings)
+		}
+	}
+
+	if _, err := NewDbTester(nil).WithSettings(Settings{Total: 1, Concurrency: 1, PageSize: 1, Payload: "fixed:1"}); err != nil {
+		t.Errorf("counts of 1 were rejected: %s", err)
+	}
+}
+
+func TestWithSettingsRate(t *testing.T) {
+	tester, err := NewDbTester(nil).WithSettings(Settings{Rate: 200})
+	if err != nil || tester.mode != ModeRate || tester.rate != 200 {
+		t.Errorf("a rate on its own gave mode %q at %.2f ops/s (%v), want rate mode at 200", tester.mode, tester.rate, err)
+	}
+	if _, err := NewDbTester(nil).WithSettings(Settings{Mode: ModePool, Rate: 200}); err == nil {
+		t.Errorf("a rate in pool mode was accepted")
+	}
+	if _, err := NewDbTester(nil).WithSettings(Settings{Rate: -1}); err == nil {
+		t.Errorf("a negative rate was accepted")
+	}
+}
+
+func TestParsePayloadRejectsSizesBelowOne(t *testing.T) {
+	for _, spec := range []string{"fixed:0", "fixed:-1", "fixed:0.5", "uniform:0:10", "uniform:-5:-1", "lognormal:0:1:random"} {
+		if _, err := ParsePayload(spec); err == nil {
+			t.Errorf("%q was accepted", spec)
+		}
+	}
+	for _, spec := range []string{"fixed:1", "uniform:1:10", "lognormal:1024:1:unicode"} {
+		if _, err := ParsePayload(spec); err != nil {
+			t.Errorf("%q was rejected: %s", spec, err)
+		}
+	}
+}
@@ -0,0 +1,355 @@
+package test
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The number of bits used for the linear sub-buckets inside each power of two.
+// Seven bits gives 64 sub-buckets per power of two above 128ns, so every
+// recorded value is within 1/64 (about 1.6%) of its real value.
+const histogramSubBucketBits = 7
+const histogramSubBucketCount = 1 << histogramSubBucketBits
+const histogramSubBucketHalf = histogramSubBucketCount / 2
+
+// A log-linear latency histogram in the style of HdrHistogram. Histograms from
+// different runs can be merged without losing any precision.
+type Histogram struct {
+	Counts     []int64 `json:"counts"`     // The number of values recorded in each bucket.
+	Count      int64   `json:"count"`      // The total number of recorded values.
+	Min        int64   `json:"min"`        // The smallest recorded value in nanoseconds.
+	Max        int64   `json:"max"`        // The largest recorded value in nanoseconds.
+	Sum        int64   `json:"sum"`        // The sum of all recorded values in nanoseconds.
+	SumSquares float64 `json:"sumSquares"` // The sum of the squares of all recorded values.
+}
+
+// Creates an empty histogram.
+func NewHistogram() *Histogram {
+	return &Histogram{Counts: make([]int64, 0)}
+}
+
+// Records a single duration.
+func (hist *Histogram) Record(duration time.Duration) {
+	value := duration.Nanoseconds()
+	if value < 0 {
+		value = 0
+	}
+	index := histogramIndex(value)
+	if index >= len(hist.Counts) {
+		counts := make([]int64, index+1)
+		copy(counts, hist.Counts)
+		hist.Counts = counts
+	}
+	hist.Counts[index]++
+	if hist.Count == 0 || value < hist.Min {
+		hist.Min = value
+	}
+	if value > hist.Max {
+		hist.Max = value
+	}
+	hist.Count++
+	hist.Sum += value
+	hist.SumSquares += float64(value) * float64(value)
+}
+
+// Adds all the values recorded in the other histogram to this histogram.
+func (hist *Histogram) Merge(other *Histogram) {
+	if other == nil || other.Count == 0 {
+		return
+	}
+	if len(other.Counts) > len(hist.Counts) {
+		counts := make([]int64, len(other.Counts))
+		copy(counts, hist.Counts)
+		hist.Counts = counts
+	}
+	for i, count := range other.Counts {
+		hist.Counts[i] += count
+	}
+	if hist.Count == 0 || other.Min < hist.Min {
+		hist.Min = other.Min
+	}
+	if other.Max > hist.Max {
+		hist.Max = other.Max
+	}
+	hist.Count += other.Count
+	hist.Sum += other.Sum
+	hist.SumSquares += other.SumSquares
+}
+
+// Returns a deep copy of the histogram.
+func (hist *Histogram) Copy() *Histogram {
+	copied := *hist
+	copied.Counts = append([]int64{}, hist.Counts...)
+	return &copied
+}
+
+// Returns the recorded value at the given percentile (0-100).
+func (hist *Histogram) ValueAtPercentile(percentile float64) time.Duration {
+	if hist.Count == 0 {
+		return 0
+	}
+	target := int64(math.Ceil(percentile / 100 * float64(hist.Count)))
+	if target < 1 {
+		target = 1
+	}
+	var seen int64
+	for index, count := range hist.Counts {
+		seen += count
+		if seen >= target {
+			value := histogramHighestEquivalent(index)
+			if value > hist.Max {
+				value = hist.Max
+			}
+			if value < hist.Min {
+				value = hist.Min
+			}
+			return time.Duration(value)
+		}
+	}
+	return time.Duration(hist.Max)
+}
+
+// Returns the mean of the recorded values.
+func (hist *Histogram) Mean() time.Duration {
+	if hist.Count == 0 {
+		return 0
+	}
+	return time.Duration(float64(hist.Sum) / float64(hist.Count))
+}
+
+// Returns the population standard deviation of the recorded values.
+func (hist *Histogram) StdDev() time.Duration {
+	if hist.Count == 0 {
+		return 0
+	}
+	mean := float64(hist.Sum) / float64(hist.Count)
+	variance := hist.SumSquares/float64(hist.Count) - mean*mean
+	if variance < 0 {
+		variance = 0
+	}
+	return time.Duration(math.Sqrt(variance))
+}
+
+// Finds the bucket a value in nanoseconds belongs to.
+func histogramIndex(value int64) int {
+	if value < histogramSubBucketCount {
+		return int(value)
+	}
+	shift := bits.Len64(uint64(value)) - histogramSubBucketBits
+	return histogramSubBucketHalf*shift + int(value>>shift)
+}
+
+// Finds the largest value in nanoseconds that belongs to the given bucket.
+func histogramHighestEquivalent(index int) int64 {
+	if index < histogramSubBucketCount {
+		return int64(index)
+	}
+	shift := index/histogramSubBucketHalf - 1
+	subBucket := int64(index - histogramSubBucketHalf*shift)
+	return (subBucket+1)<<shift - 1
+}
+
+// Latency statistics for a single timed phase.
+type Result struct {
+	RunID     string         // The ID of the run, if it has one.
+	Mode      string         // How the operations were scheduled.
+	Workload  string         // The mixed workload that was run, if any.
+	Keys      string         // The key distribution and seed used to pick existing keys, if any.
+	Payload   string         // How the text of written test data was generated, if not the default.
+	Count     int            // The number of operations that were timed.
+	Total     time.Duration  // The sum of every operation latency.
+	Min       time.Duration  // The fastest operation.
+	Max       time.Duration  // The slowest operation.
+	Mean      time.Duration  // The average operation latency.
+	StdDev    time.Duration  // The standard deviation of the operation latencies.
+	P50       time.Duration  // The median operation latency.
+	P90       time.Duration  // The 90th percentile operation latency.
+	P99       time.Duration  // The 99th percentile operation latency.
+	P999      time.Duration  // The 99.9th percentile operation latency.
+	Histogram *Histogram     // The full latency distribution.
+	Elapsed   time.Duration  // The wall-clock time of the run, including pauses.
+	Paused    time.Duration  // The time spent pausing between wait groups.
+	Failed    int            // The number of operations that returned an error.
+	Rows      int64          // The number of records handled by operations that handle more than one.
+	Bytes     int64          // The number of text bytes read or written.
+	Errors    map[string]int // The number of failed operations by error class.
+	Warmup    *Result        // The operations run before timing started, if there was a warmup.
+
+	Operations   map[string]Result // The statistics for each operation type in a mixed workload.
+	Verification *Verification     // How the reads compared with the written test data, if they were verified.
+	Contention   *Contention       // How the counters held up, for contended read-modify-write runs.
+}
+
+// Returns the records handled per second while operations were running,
+// leaving out the pauses between wait groups.
+func (result Result) RowsPerSecond() float64 {
+	return perSecond(int(result.Rows), result.Active())
+}
+
+// Returns the megabytes of text read or written per second while operations
+// were running, leaving out the pauses between wait groups.
+func (result Result) MBPerSecond() float64 {
+	if result.Active() <= 0 {
+		return 0
+	}
+	return float64(result.Bytes) / 1e6 / result.Active().Seconds()
+}
+
+// Returns the latency sum divided by the records handled, the cost of each
+// record when operations handle more than one.
+func (result Result) PerRow() time.Duration {
+	if result.Rows == 0 {
+		return 0
+	}
+	return result.Total / time.Duration(result.Rows)
+}
+
+// Returns the fraction of attempted operations that failed.
+func (result Result) ErrorRate() float64 {
+	attempted := result.Count + result.Failed
+	if attempted == 0 {
+		return 0
+	}
+	return float64(result.Failed) / float64(attempted)
+}
+
+// Returns the wall-clock time of the run without the pauses between wait groups.
+func (result Result) Active() time.Duration {
+	return result.Elapsed - result.Paused
+}
+
+// Returns the achieved operations per second over the whole run, including pauses.
+func (result Result) Throughput() float64 {
+	return perSecond(result.Count, result.Elapsed)
+}
+
+// Returns the achieved operations per second while operations were running.
+func (result Result) ActiveThroughput() float64 {
+	return perSecond(result.Count, result.Active())
+}
+
+// Divides the count by the duration in seconds.
+func perSecond(count int, duration time.Duration) float64 {
+	if duration <= 0 {
+		return 0
+	}
+	return float64(count) / duration.Seconds()
+}
+
+// Summarizes the histogram into a result.
+func newResult(hist *Histogram) Result {
+	return Result{
+		Count:     int(hist.Count),
+		Total:     time.Duration(hist.Sum),
+		Min:       time.Duration(hist.Min),
+		Max:       time.Duration(hist.Max),
+		Mean:      hist.Mean(),
+		StdDev:    hist.StdDev(),
+		P50:       hist.ValueAtPercentile(50),
+		P90:       hist.ValueAtPercentile(90),
+		P99:       hist.ValueAtPercentile(99),
+		P999:      hist.ValueAtPercentile(99.9),
+		Histogram: hist,
+		Errors:    make(map[string]int),
+	}
+}
+
+// Combines two results into one as if every operation was part of the same run.
+func (result Result) Merge(other Result) Result {
+	hist := NewHistogram()
+	hist.Merge(result.Histogram)
+	hist.Merge(other.Histogram)
+	merged := newResult(hist)
+	merged.Mode = result.Mode
+	merged.Workload = result.Workload
+	merged.Keys = result.Keys
+	merged.RunID = result.RunID
+	merged.Payload = result.Payload
+	merged.Elapsed = result.Elapsed + other.Elapsed
+	merged.Paused = result.Paused + other.Paused
+	merged.Failed = result.Failed + other.Failed
+	merged.Rows = result.Rows + other.Rows
+	merged.Bytes = result.Bytes + other.Bytes
+	merged.Errors = make(map[string]int)
+	for class, count := range result.Errors {
+		merged.Errors[class] += count
+	}
+	for class, count := range other.Errors {
+		merged.Errors[class] += count
+	}
+	if len(result.Operations) > 0 || len(other.Operations) > 0 {
+		merged.Operations = make(map[string]Result)
+		for kind, operation := range result.Operations {
+			merged.Operations[kind] = operation
+		}
+		for kind, operation := range other.Operations {
+			if existing, ok := merged.Operations[kind]; ok {
+				operation = existing.Merge(operation)
+			}
+			merged.Operations[kind] = operation
+		}
+	}
+	merged.Verification = result.Verification.merge(other.Verification)
+	merged.Contention = result.Contention.merge(other.Contention)
+	if result.Warmup != nil && other.Warmup != nil {
+		warmup := result.Warmup.Merge(*other.Warmup)
+		merged.Warmup = &warmup
+	} else if result.Warmup != nil {
+		merged.Warmup = result.Warmup
+	} else {
+		merged.Warmup = other.Warmup
+	}
+	return merged
+}
+
+func (result Result) String() string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "  mode: %s\n", result.Mode)
+	if result.Workload != "" {
+		fmt.Fprintf(&builder, "  workload: %s\n", result.Workload)
+	}
+	if result.Keys != "" {
+		fmt.Fprintf(&builder, "  keys: %s\n", result.Keys)
+	}
+	if result.Payload != "" {
+		fmt.Fprintf(&builder, "  payload: %s\n", result.Payload)
+	}
+	fmt.Fprintf(&builder, "  latency: min %s, p50 %s, p90 %s, p99 %s, p99.9 %s, max %s\n", result.Min, result.P50, result.P90, result.P99, result.P999, result.Max)
+	fmt.Fprintf(&builder, "  mean %s, stddev %s, latency sum %s\n", result.Mean, result.StdDev, result.Total)
+	fmt.Fprintf(&builder, "  elapsed %s (%s without pauses), throughput %.2f ops/s (%.2f ops/s without pauses)", result.Elapsed, result.Active(), result.Throughput(), result.ActiveThroughput())
+	if result.Rows > 0 {
+		fmt.Fprintf(&builder, "\n  rows %d (%.2f rows/s, %.2f rows per operation, %s per row)", result.Rows, result.RowsPerSecond(), float64(result.Rows)/float64(result.Count), result.PerRow())
+	}
+	if result.Payload != "" && result.Bytes > 0 {
+		fmt.Fprintf(&builder, "\n  bytes %d (%.2f MB/s)", result.Bytes, result.MBPerSecond())
+	}
+	fmt.Fprintf(&builder, "\n  errors %d of %d (%.2f%%)", result.Failed, result.Count+result.Failed, result.ErrorRate()*100)
+	if result.Failed > 0 {
+		fmt.Fprintf(&builder, ": %s", formatErrors(result.Errors))
+	}
+	kinds := make([]string, 0, len(result.Operations))
+	for kind := range result.Operations {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		operation := result.Operations[kind]
+		fmt.Fprintf(&builder, "\n  %s: %d ops (%.1f%%), p50 %s, p99 %s, mean %s, errors %d (%.2f%%)", kind, operation.Count+operation.Failed,
+			float64(operation.Count+operation.Failed)/float64(result.Count+result.Failed)*100, operation.P50, operation.P99, operation.Mean, operation.Failed, operation.ErrorRate()*100)
+	}
+	if result.Verification != nil {
+		fmt.Fprintf(&builder, "\n  %s", result.Verification)
+	}
+	if result.Contention != nil {
+		fmt.Fprintf(&builder, "\n  %s", result.Contention)
+	}
+	if result.Warmup != nil {
+		warmup := strings.ReplaceAll(result.Warmup.String(), "\n", "\n  ")
+		fmt.Fprintf(&builder, "\n  warmup (excluded from the statistics above):\n  %s", warmup)
+	}
+	return builder.String()
+}
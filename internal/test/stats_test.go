@@ -0,0 +1,100 @@
+package test
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestHistogramIndexPrecision(t *testing.T) {
+	for _, value := range []int64{0, 1, 127, 128, 129, 255, 256, 1000, 4095, 65536, 999_999, 1_000_000_007, 1 << 40} {
+		index := histogramIndex(value)
+		highest := histogramHighestEquivalent(index)
+		if highest < value {
+			t.Errorf("value %d: bucket %d ends at %d, below the value", value, index, highest)
+		}
+		if float64(highest-value) > float64(value)/64 {
+			t.Errorf("value %d: bucket %d ends at %d, more than 1/64 above the value", value, index, highest)
+		}
+		if index > 0 && histogramHighestEquivalent(index-1) >= value {
+			t.Errorf("value %d: the previous bucket %d already covers it", value, index-1)
+		}
+	}
+}
+
+func TestHistogramIndexIsMonotonic(t *testing.T) {
+	previous := 0
+	for value := int64(0); value < 1<<20; value++ {
+		index := histogramIndex(value)
+		if index < previous {
+			t.Fatalf("value %d: bucket %d comes before bucket %d of the value before it", value, index, previous)
+		}
+		previous = index
+	}
+}
+
+func TestHistogramPercentiles(t *testing.T) {
+	hist := NewHistogram()
+	for i := 1; i <= 1000; i++ {
+		hist.Record(time.Duration(i) * time.Microsecond)
+	}
+	tests := []struct {
+		percentile float64
+		want       time.Duration
+	}{
+		{0, time.Microsecond},
+		{50, 500 * time.Microsecond},
+		{90, 900 * time.Microsecond},
+		{99, 990 * time.Microsecond},
+		{100, 1000 * time.Microsecond},
+	}
+	for _, test := range tests {
+		got := hist.ValueAtPercentile(test.percentile)
+		if got < test.want || float64(got-test.want) > float64(test.want)/64 {
+			t.Errorf("p%v = %s, want %s within 1/64", test.percentile, got, test.want)
+		}
+	}
+	if hist.Mean() != 500500*time.Nanosecond {
+		t.Errorf("mean = %s, want 500.5µs", hist.Mean())
+	}
+}
+
+func TestHistogramMerge(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	whole, first, second := NewHistogram(), NewHistogram(), NewHistogram()
+	for i := 0; i < 10000; i++ {
+		value := time.Duration(random.Int63n(int64(time.Second)))
+		whole.Record(value)
+		if i%2 == 0 {
+			first.Record(value)
+		} else {
+			second.Record(value)
+		}
+	}
+	first.Merge(second)
+	for _, percentile := range []float64{1, 50, 99, 99.9} {
+		if got, want := first.ValueAtPercentile(percentile), whole.ValueAtPercentile(percentile); got != want {
+			t.Errorf("merged p%v = %s, want %s", percentile, got, want)
+		}
+	}
+	if first.Count != whole.Count || first.Min != whole.Min || first.Max != whole.Max || first.Sum != whole.Sum {
+		t.Errorf("merged histogram %+v does not match %+v", first, whole)
+	}
+}
+
+func TestMBPerSecondLeavesOutPauses(t *testing.T) {
+	result := Result{Bytes: 2_000_000, Elapsed: 10 * time.Second, Paused: 8 * time.Second}
+	if got := result.MBPerSecond(); got != 1 {
+		t.Errorf("%.2f MB/s, want 1", got)
+	}
+	if got := (Result{Bytes: 1_000_000}).MBPerSecond(); got != 0 {
+		t.Errorf("%.2f MB/s without elapsed time, want 0", got)
+	}
+}
+
+func TestRowsPerSecondLeavesOutPauses(t *testing.T) {
+	result := Result{Rows: 400, Elapsed: 10 * time.Second, Paused: 6 * time.Second}
+	if got := result.RowsPerSecond(); got != 100 {
+		t.Errorf("%.2f rows/s, want 100", got)
+	}
+}
@@ -1,10 +1,9 @@
 package test
 
 import (
+	"context"
 	"fmt"
-	"math"
-	"os"
-	"sync"
+	"math/rand"
 	"time"
 )
 
@@ -25,120 +24,173 @@ type TestDatabase interface {
 	ReadTestData(int64) (*TestData, error) // Reads test data from the database.
 }
 
+// A test database that stops its requests when the context is canceled or its
+// deadline passes.
+type ContextTestDatabase interface {
+	WriteTestDataContext(context.Context, TestData) error          // Writes test data to the database.
+	ReadTestDataContext(context.Context, int64) (*TestData, error) // Reads test data from the database.
+}
+
+// Wraps a test database without context support so it can be used as one that
+// has it. The context is only checked before each request is sent.
+type contextDatabase struct {
+	db TestDatabase
+}
+
+func (db contextDatabase) WriteTestDataContext(ctx context.Context, data TestData) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return db.db.WriteTestData(data)
+}
+
+func (db contextDatabase) ReadTestDataContext(ctx context.Context, key int64) (*TestData, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return db.db.ReadTestData(key)
+}
+
+// Returns the context-aware version of the test database.
+func withContext(db TestDatabase) ContextTestDatabase {
+	if ctxDb, ok := db.(ContextTestDatabase); ok {
+		return ctxDb
+	}
+	return contextDatabase{db: db}
+}
+
 const testTotalWriteDefault = 1000
 const testWaitGroupDefault = 100
 const testPauseTimeDefault = 0
+const testMaxErrorRateDefault = 1
+const testErrorRateMinimumOps = 20
+const testPageSizeDefault = 50
 
 // A tester object used to run distributed database tests for reads and writes.
 type dbTester struct {
-	db        TestDatabase  // The database to run the tests on. This is required.
-	total     int           // The total number of requests that should be sent.
-	waitGroup int           // The number of concurrent requests to send at a time.
-	pause     time.Duration // The time to pause between each wait group.
-	verbose   bool          // Whether to log extra info.
-}
-
-// Writes the total amount of test data to the test database
-func (tester *dbTester) TimeWrites() (time.Duration, time.Duration) {
-	total, waitGroup, pause := tester.total, tester.waitGroup, tester.pause
-	var writeMutex sync.Mutex
-
-	var wg sync.WaitGroup
-	writeTimes := make([]time.Duration, 0)
-	for i := 1; i <= int(math.Ceil(float64(total)/float64(waitGroup))); i++ {
-		for j := 1; j <= waitGroup; j++ {
-			key := (i-1)*waitGroup + j
-			wg.Add(1)
-			data := TestData{
-				Key:       int64(key),
-				Timestamp: time.Now().Add(time.Duration(key) * time.Second),
-				Text:      fmt.Sprintf("SampleText-%d", key),
-			}
-			go func(data TestData) {
-				if tester.verbose {
-					fmt.Printf("Started writing %d.\n", key)
-				}
-				defer wg.Done()
-				writeStart := time.Now()
-				err := tester.db.WriteTestData(data)
-				writeDuration := time.Since(writeStart)
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "Unable to write test data: %s\n", err.Error())
-					os.Exit(1)
-				}
-				if tester.verbose {
-					fmt.Printf("Finished writing %d in %s.\n", key, writeDuration)
-				}
-
-				writeMutex.Lock()
-				writeTimes = append(writeTimes, writeDuration)
-				writeMutex.Unlock()
-			}(data)
-		}
-		wg.Wait()
-		if tester.verbose {
-			fmt.Printf("Wrote %d records.\n", waitGroup)
-			fmt.Printf("Waiting for %s.\n", pause)
+	db              TestDatabase    // The database to run the tests on. This is required.
+	total           int             // The total number of requests that should be sent.
+	waitGroup       int             // The number of concurrent requests to send at a time, or workers in pool mode.
+	pause           time.Duration   // The time to pause between each wait group.
+	verbose         bool            // Whether to log extra info.
+	maxErrorRate    float64         // The fraction of failed operations that aborts a run.
+	opTimeout       time.Duration   // The deadline for each operation. Zero means no deadline.
+	mode            string          // How operations are scheduled, one of the Mode constants.
+	rate            float64         // The operations per second to send in rate mode.
+	duration        time.Duration   // How long each run lasts. Zero means each run sends total operations.
+	keys            *keySpace       // The keys written so far, shared by every copy of the tester.
+	warmup          int             // The number of operations to run before timing starts.
+	warmupDuration  time.Duration   // How long to run operations before timing starts.
+	keyDistribution KeyDistribution // How keys are picked for reads and updates. Nil means the phase's default.
+	seed            int64           // The seed for every random choice the tester makes.
+	verify          bool            // Whether reads are compared with the written test data.
+	verifyPrecision time.Duration   // The precision timestamps are compared at when verifying.
+	pageSize        int             // The number of test data to fetch per scan.
+	payload         Payload         // Generates the text of written test data. Nil means short sample text.
+	runID           string          // Identifies the run in reports. The test database uses it to keep runs apart.
+	maxVariation    float64         // The coefficient of variation above which a statistic is unstable over trials.
+	offset          int             // The number of operations earlier rounds of a comparison ran, which the keys carry on from.
+}
+
+// Writes the total amount of test data, or as much as fits in the tester's
+// duration, to the test database and returns the latency statistics. The error
+// is ErrAborted if too many writes failed, or the context's error if it was
+// canceled, in which case the result only covers the writes that finished.
+func (tester dbTester) TimeWrites(ctx context.Context) (Result, error) {
+	db := withContext(tester.db)
+	return tester.timePrepared(ctx, "writing", tester.writeKeys(), func(key int64) operation {
+		data := tester.newTestData(key, "SampleText")
+		return func(ctx context.Context, _ int64) (outcome, error) {
+			return tester.write(ctx, db, data)
 		}
-		time.Sleep(pause)
+	})
+}
+
+// Reads back the written test data and returns the latency statistics. The
+// keys are picked with the tester's key distribution. By default the written
+// keys are read in order, starting over if the tester's total or duration is
+// larger than the number of keys written.
+// The error is ErrAborted if too many reads failed, or the context's error if it
+// was canceled, in which case the result only covers the reads that finished.
+func (tester dbTester) TimeReads(ctx context.Context) (Result, error) {
+	db := withContext(tester.db)
+	distribution := tester.keyDistributionOr(SequentialKeys())
+	keys := tester.readKeys(newLockedRand(tester.seed), distribution)
+	v := tester.newVerifier()
+	result, err := tester.timeOperations(ctx, "reading", keys, func(ctx context.Context, key int64) (outcome, error) {
+		return tester.read(ctx, db, key, v)
+	})
+	result.Keys = tester.describeKeys(distribution)
+	if v != nil {
+		result.Verification = v.result()
 	}
+	return result, err
+}
 
-	if tester.verbose {
-		fmt.Printf("Done writing %d records.\n", total)
+// Returns the tester's key distribution, or the fallback if none was chosen.
+// Either way the run gets a fresh copy, so runs that share a distribution,
+// like the YCSB presets, do not share its cached state.
+func (tester dbTester) keyDistributionOr(fallback KeyDistribution) KeyDistribution {
+	if tester.keyDistribution != nil {
+		return freshKeys(tester.keyDistribution)
 	}
+	return freshKeys(fallback)
+}
 
-	totalTime := sum(writeTimes)
-	averageTime := float64(totalTime) / float64(len(writeTimes))
-	return time.Duration(totalTime), time.Duration(averageTime)
-}
-
-// Writes a set of test data.
-func (tester dbTester) TimeReads() (time.Duration, time.Duration) {
-	total, waitGroup, pause := tester.total, tester.waitGroup, tester.pause
-	var readMutex sync.Mutex
-
-	var wg sync.WaitGroup
-	readTimes := make([]time.Duration, 0)
-	for i := 1; i <= int(math.Ceil(float64(total)/float64(waitGroup))); i++ {
-		for j := 1; j <= waitGroup; j++ {
-			key := (i-1)*waitGroup + j
-			wg.Add(1)
-			go func(key int64) {
-				if tester.verbose {
-					fmt.Printf("Started reading %d.\n", key)
-				}
-				defer wg.Done()
-				readStart := time.Now()
-				data, err := tester.db.ReadTestData(key)
-				readDuration := time.Since(readStart)
-				if tester.verbose {
-					fmt.Printf("Finished reading { key = %d, text = %s, time = %v } in %s.\n", data.Key, data.Text, data.Timestamp, readDuration)
-				}
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "Unable to read test data: %s\n", err.Error())
-					os.Exit(1)
-				}
-
-				readMutex.Lock()
-				readTimes = append(readTimes, readDuration)
-				readMutex.Unlock()
-			}(int64(key))
-		}
-		wg.Wait()
-		if tester.verbose {
-			fmt.Printf("Read %d records.\n", waitGroup)
-			fmt.Printf("Waiting for %s.\n", pause)
-		}
-		time.Sleep(pause)
+// Describes the key distribution and seed, for reports.
+func (tester dbTester) describeKeys(distribution KeyDistribution) string {
+	return fmt.Sprintf("%s, seed %d", distribution, tester.seed)
+}
+
+// Writes the new test data and adds its key to the key space.
+func (tester dbTester) write(ctx context.Context, db ContextTestDatabase, data TestData) (outcome, error) {
+	if err := db.WriteTestDataContext(ctx, data); err != nil {
+		return outcome{}, err
+	}
+	tester.keys.add(data)
+	return outcome{bytes: len(data.Text)}, nil
+}
+
+// Creates the test data that is written for the key. Without a payload the
+// text is the prefix followed by the key.
+func (tester dbTester) newTestData(key int64, prefix string) TestData {
+	data := TestData{
+		Key:       key,
+		Timestamp: time.Now().Add(time.Duration(key) * time.Second),
+		Text:      fmt.Sprintf("%s-%d", prefix, key),
+	}
+	if tester.payload != nil {
+		// Seeded by key and prefix so the same run writes the same text no
+		// matter which order the operations run in.
+		random := rand.New(rand.NewSource(tester.seed + key*31 + int64(len(prefix))))
+		data.Text = tester.payload.Text(random)
 	}
+	return data
+}
 
-	if tester.verbose {
-		fmt.Printf("Done reading %d records.\n", total)
+// Reads the test data for the key. If the verifier is set the test data is
+// compared with what was written.
+func (tester dbTester) read(ctx context.Context, db ContextTestDatabase, key int64, v *verifier) (outcome, error) {
+	before := tester.keys.expected(key)
+	data, err := db.ReadTestDataContext(ctx, key)
+	if err == nil && data == nil {
+		err = ErrNotFound
+	}
+	if v != nil {
+		v.check(key, data, err, append(before, tester.keys.expected(key)...))
+	}
+	if err != nil {
+		return outcome{}, err
 	}
+	return outcome{bytes: len(data.Text)}, nil
+}
 
-	totalTime := sum(readTimes)
-	averageTime := float64(totalTime) / float64(len(readTimes))
-	return time.Duration(totalTime), time.Duration(averageTime)
+// Creates a verifier if the tester verifies reads.
+func (tester dbTester) newVerifier() *verifier {
+	if !tester.verify {
+		return nil
+	}
+	return newVerifier(tester.verifyPrecision)
 }
 
 // Creates new database tester using the given database.
@@ -148,12 +200,95 @@ func NewDbTester(db TestDatabase) (tester dbTester) {
 	tester.pause = testPauseTimeDefault
 	tester.waitGroup = testWaitGroupDefault
 	tester.verbose = false
+	tester.maxErrorRate = testMaxErrorRateDefault
+	tester.mode = ModeBatch
+	tester.keys = newKeySpace()
+	tester.seed = time.Now().UnixNano()
+	tester.pageSize = testPageSizeDefault
+	tester.maxVariation = testMaxVariationDefault
 	return
 }
 
-// Sets the total size of test data to read and write.
+// Sets the total size of test data to read and write. This turns off any
+// duration set with WithDuration.
 func (tester dbTester) WithTotal(total int) dbTester {
 	tester.total = total
+	tester.duration = 0
+	return tester
+}
+
+// Runs each phase for the given duration instead of a total number of
+// operations. Writes keep adding new keys until the time is up.
+func (tester dbTester) WithDuration(duration time.Duration) dbTester {
+	tester.duration = duration
+	return tester
+}
+
+// Runs the given number of operations before each phase. Warmup operations are
+// sent the same way as the rest but are reported separately, so connection
+// setup does not skew the statistics.
+func (tester dbTester) WithWarmup(warmup int) dbTester {
+	tester.warmup = warmup
+	tester.warmupDuration = 0
+	return tester
+}
+
+// Runs operations for the given duration before each phase. Warmup operations
+// are sent the same way as the rest but are reported separately.
+func (tester dbTester) WithWarmupDuration(warmupDuration time.Duration) dbTester {
+	tester.warmup = 0
+	tester.warmupDuration = warmupDuration
+	return tester
+}
+
+// Sets how keys are picked for reads, updates and the other operations on
+// existing test data.
+func (tester dbTester) WithKeyDistribution(keyDistribution KeyDistribution) dbTester {
+	tester.keyDistribution = keyDistribution
+	return tester
+}
+
+// Sets the seed for random choices, so a run can be repeated exactly. By
+// default the seed comes from the time the tester was created.
+func (tester dbTester) WithSeed(seed int64) dbTester {
+	tester.seed = seed
+	return tester
+}
+
+// Compares every read with the test data that was written, reporting
+// mismatches, missing test data and timestamps that are off by less than the
+// precision, as when a database truncates or rounds what it stores.
+func (tester dbTester) WithVerify(precision time.Duration) dbTester {
+	tester.verify = true
+	tester.verifyPrecision = precision
+	return tester
+}
+
+// Sets how the text of written and updated test data is generated. By default
+// the text is a short sample followed by the key.
+func (tester dbTester) WithPayload(payload Payload) dbTester {
+	tester.payload = payload
+	return tester
+}
+
+// Labels every result with the run ID. Pass the same ID to the test database
+// so the reports can be matched with the tables or keys the run used.
+func (tester dbTester) WithRunID(runID string) dbTester {
+	tester.runID = runID
+	return tester
+}
+
+// Sets the coefficient of variation (standard deviation over mean) above which
+// a statistic is flagged as too noisy to trust when running trials. For
+// example 0.1 flags statistics that vary by more than 10% between trials.
+func (tester dbTester) WithMaxVariation(maxVariation float64) dbTester {
+	tester.maxVariation = maxVariation
+	return tester
+}
+
+// Sets the number of test data each scan fetches.
+func (tester dbTester) WithPageSize(pageSize int) dbTester {
+	tester.pageSize = pageSize
 	return tester
 }
 
@@ -169,6 +304,46 @@ func (tester dbTester) WithWaitGroup(waitGroup int) dbTester {
 	return tester
 }
 
+// Sets the fraction of failed operations (0-1) after which a run is aborted.
+// For example 0.05 stops a run once more than 5% of its operations fail.
+func (tester dbTester) WithMaxErrorRate(maxErrorRate float64) dbTester {
+	tester.maxErrorRate = maxErrorRate
+	return tester
+}
+
+// Sets the deadline for each individual operation. Operations that take longer
+// are canceled and counted as timeouts.
+func (tester dbTester) WithOpTimeout(opTimeout time.Duration) dbTester {
+	tester.opTimeout = opTimeout
+	return tester
+}
+
+// Sends operations at a constant rate instead of in wait groups. Operations are
+// sent on schedule even if earlier ones have not finished, and latency is
+// measured from when each operation should have been sent. The rate must be
+// above zero, or the runs fail without sending anything.
+func (tester dbTester) WithRate(opsPerSecond float64) dbTester {
+	tester.mode = ModeRate
+	tester.rate = opsPerSecond
+	return tester
+}
+
+// Sends operations through a pool of workers that each take the next key as
+// soon as their previous operation finishes. Concurrency stays at the number
+// of workers for the whole run and there are no pauses.
+func (tester dbTester) WithWorkers(workers int) dbTester {
+	tester.mode = ModePool
+	tester.waitGroup = workers
+	return tester
+}
+
+// Sends operations in wait groups, waiting for each group to finish before the
+// next one is sent. This is the default mode and matches the original results.
+func (tester dbTester) WithBatches() dbTester {
+	tester.mode = ModeBatch
+	return tester
+}
+
 // Turns on verbose mode.
 func (tester dbTester) WithVerbose() dbTester {
 	tester.verbose = true
@@ -180,12 +355,3 @@ func (tester dbTester) WithoutVerbose() dbTester {
 	tester.verbose = false
 	return tester
 }
-
-// Sums the durations in the slice and returns the total nanoseconds.
-func sum(data []time.Duration) (result int64) {
-	result = 0
-	for _, duration := range data {
-		result += duration.Nanoseconds()
-	}
-	return
-}
@@ -0,0 +1,201 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The number of bootstrap resamples used for each confidence interval.
+const trialResamples = 2000
+
+// The confidence level of the intervals, as a fraction.
+const trialConfidence = 0.95
+
+// The coefficient of variation above which a statistic is flagged as too noisy
+// to trust, unless the tester sets its own.
+const testMaxVariationDefault = 0.1
+
+// How a statistic varied over repeated trials.
+type Estimate struct {
+	Name       string    // The statistic, for example "p99".
+	Unit       string    // "ns" for latencies, "ops/s" for throughput and "%" for error rates.
+	Values     []float64 // The statistic in each trial, in the order the trials ran.
+	Mean       float64   // The mean over the trials.
+	MeanLow    float64   // The lower bound of the bootstrap confidence interval of the mean.
+	MeanHigh   float64   // The upper bound of the bootstrap confidence interval of the mean.
+	Median     float64   // The median over the trials.
+	MedianLow  float64   // The lower bound of the bootstrap confidence interval of the median.
+	MedianHigh float64   // The upper bound of the bootstrap confidence interval of the median.
+	Variation  float64   // The coefficient of variation, the standard deviation divided by the mean.
+	Unstable   bool      // Whether the variation is above the tester's maximum.
+}
+
+// The results of running the same phase several times.
+type Trials struct {
+	Runs         []Result   // The result of each trial.
+	Merged       Result     // Every trial merged into one result.
+	Estimates    []Estimate // How each statistic varied over the trials.
+	Confidence   float64    // The confidence level of the intervals.
+	MaxVariation float64    // The coefficient of variation above which a statistic is unstable.
+}
+
+// Whether any statistic varied too much between trials to be trusted.
+func (trials Trials) Unstable() bool {
+	for _, estimate := range trials.Estimates {
+		if estimate.Unstable {
+			return true
+		}
+	}
+	return false
+}
+
+func (trials Trials) String() string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "%d trials, %.0f%% bootstrap confidence intervals", len(trials.Runs), trials.Confidence*100)
+	for _, estimate := range trials.Estimates {
+		fmt.Fprintf(&builder, "\n  %s", estimate)
+	}
+	if trials.Unstable() {
+		fmt.Fprintf(&builder, "\n  UNSTABLE: some statistics varied by more than %.0f%% between trials, so the results are too noisy to trust", trials.MaxVariation*100)
+	}
+	return builder.String()
+}
+
+func (estimate Estimate) String() string {
+	line := fmt.Sprintf("%s: mean %s (%s to %s), median %s (%s to %s), variation %.1f%%", estimate.Name,
+		estimate.format(estimate.Mean), estimate.format(estimate.MeanLow), estimate.format(estimate.MeanHigh),
+		estimate.format(estimate.Median), estimate.format(estimate.MedianLow), estimate.format(estimate.MedianHigh), estimate.Variation*100)
+	if estimate.Unstable {
+		line += " UNSTABLE"
+	}
+	return line
+}
+
+// Formats a value of the statistic in its unit.
+func (estimate Estimate) format(value float64) string {
+	switch estimate.Unit {
+	case "ns":
+		return time.Duration(value).Round(time.Microsecond).String()
+	case "%":
+		return fmt.Sprintf("%.2f%%", value)
+	}
+	return fmt.Sprintf("%.2f %s", value, estimate.Unit)
+}
+
+// Runs the phase the given number of times, one after the other, and
+// estimates how much each statistic varies with bootstrap confidence intervals
+// of its mean and median. Statistics whose coefficient of variation is above
+// the tester's maximum are flagged as unstable. The phase is usually one of the
+// tester's own, like TimeReads. If a trial fails the trials so far are
+// returned with its error.
+func (tester dbTester) Trials(ctx context.Context, count int, phase func(ctx context.Context) (Result, error)) (Trials, error) {
+	trials := Trials{Runs: make([]Result, 0, count), Confidence: trialConfidence, MaxVariation: tester.maxVariation}
+	var err error
+	for i := 0; i < count; i++ {
+		var result Result
+		result, err = phase(ctx)
+		trials.Runs = append(trials.Runs, result)
+		if err != nil {
+			break
+		}
+	}
+
+	trials.Merged = newResult(NewHistogram())
+	for i, run := range trials.Runs {
+		if i == 0 {
+			trials.Merged = run
+		} else {
+			trials.Merged = trials.Merged.Merge(run)
+		}
+	}
+	random := rand.New(rand.NewSource(tester.seed))
+	for _, statistic := range trialStatistics {
+		values := make([]float64, len(trials.Runs))
+		for i, run := range trials.Runs {
+			values[i] = statistic.value(run)
+		}
+		trials.Estimates = append(trials.Estimates, estimate(random, statistic.name, statistic.unit, values, tester.maxVariation))
+	}
+	return trials, err
+}
+
+// The statistics estimated over trials.
+var trialStatistics = []struct {
+	name  string
+	unit  string
+	value func(Result) float64
+}{
+	{"p50", "ns", func(result Result) float64 { return float64(result.P50) }},
+	{"p90", "ns", func(result Result) float64 { return float64(result.P90) }},
+	{"p99", "ns", func(result Result) float64 { return float64(result.P99) }},
+	{"p99.9", "ns", func(result Result) float64 { return float64(result.P999) }},
+	{"mean", "ns", func(result Result) float64 { return float64(result.Mean) }},
+	{"throughput", "ops/s", func(result Result) float64 { return result.Throughput() }},
+	{"error rate", "%", func(result Result) float64 { return result.ErrorRate() * 100 }},
+}
+
+// Estimates the mean and median of the values with bootstrap confidence
+// intervals: the values are resampled with replacement many times and the
+// intervals are the middle of the resampled means and medians.
+func estimate(random *rand.Rand, name string, unit string, values []float64, maxVariation float64) Estimate {
+	estimate := Estimate{Name: name, Unit: unit, Values: values}
+	if len(values) == 0 {
+		return estimate
+	}
+	estimate.Mean, estimate.Median = mean(values), median(values)
+	if estimate.Mean != 0 {
+		var squares float64
+		for _, value := range values {
+			squares += (value - estimate.Mean) * (value - estimate.Mean)
+		}
+		if len(values) > 1 {
+			estimate.Variation = math.Sqrt(squares/float64(len(values)-1)) / math.Abs(estimate.Mean)
+		}
+	}
+	estimate.Unstable = estimate.Variation > maxVariation
+
+	means := make([]float64, trialResamples)
+	medians := make([]float64, trialResamples)
+	sample := make([]float64, len(values))
+	for i := range means {
+		for j := range sample {
+			sample[j] = values[random.Intn(len(values))]
+		}
+		means[i], medians[i] = mean(sample), median(sample)
+	}
+	estimate.MeanLow, estimate.MeanHigh = interval(means)
+	estimate.MedianLow, estimate.MedianHigh = interval(medians)
+	return estimate
+}
+
+// Returns the bounds of the middle trialConfidence of the values.
+func interval(values []float64) (float64, float64) {
+	sort.Float64s(values)
+	tail := (1 - trialConfidence) / 2
+	low := int(tail * float64(len(values)))
+	high := int((1-tail)*float64(len(values))) - 1
+	return values[low], values[max(low, high)]
+}
+
+func mean(values []float64) float64 {
+	var sum float64
+	for _, value := range values {
+		sum += value
+	}
+	return sum / float64(len(values))
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
@@ -0,0 +1,105 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestEstimateIntervalsContainTheEstimate(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	values := make([]float64, 20)
+	for i := range values {
+		values[i] = 100 + random.NormFloat64()*10
+	}
+	estimate := estimate(random, "p50", "ns", values, testMaxVariationDefault)
+	if !(estimate.MeanLow <= estimate.Mean && estimate.Mean <= estimate.MeanHigh) {
+		t.Errorf("mean %f outside its interval [%f, %f]", estimate.Mean, estimate.MeanLow, estimate.MeanHigh)
+	}
+	if !(estimate.MedianLow <= estimate.Median && estimate.Median <= estimate.MedianHigh) {
+		t.Errorf("median %f outside its interval [%f, %f]", estimate.Median, estimate.MedianLow, estimate.MedianHigh)
+	}
+	if estimate.MeanLow == estimate.MeanHigh {
+		t.Errorf("the interval of varying values is empty: [%f, %f]", estimate.MeanLow, estimate.MeanHigh)
+	}
+}
+
+func TestEstimateIntervalCoverage(t *testing.T) {
+	// The interval of the mean should hold the real mean in about 95% of
+	// experiments. The bootstrap runs a little narrow for small samples, so the
+	// bound is loose.
+	random := rand.New(rand.NewSource(2))
+	const experiments = 200
+	covered := 0
+	for i := 0; i < experiments; i++ {
+		values := make([]float64, 30)
+		for j := range values {
+			values[j] = 100 + random.NormFloat64()*10
+		}
+		estimate := estimate(random, "mean", "ns", values, testMaxVariationDefault)
+		if estimate.MeanLow <= 100 && 100 <= estimate.MeanHigh {
+			covered++
+		}
+	}
+	if coverage := float64(covered) / experiments; coverage < 0.85 || coverage > 0.99 {
+		t.Errorf("the interval held the mean in %.0f%% of experiments, want about 95%%", coverage*100)
+	}
+}
+
+func TestEstimateVariation(t *testing.T) {
+	tests := []struct {
+		name      string
+		values    []float64
+		variation float64
+		unstable  bool
+	}{
+		{"constant", []float64{5, 5, 5, 5}, 0, false},
+		{"single", []float64{5}, 0, false},
+		{"noisy", []float64{1, 10, 1, 10}, 0.94475, true},
+		{"steady", []float64{99, 100, 101, 100}, 0.00816, false},
+	}
+	for _, test := range tests {
+		estimate := estimate(rand.New(rand.NewSource(1)), test.name, "ns", test.values, testMaxVariationDefault)
+		if diff := estimate.Variation - test.variation; diff < -0.001 || diff > 0.001 {
+			t.Errorf("%s: variation %f, want %f", test.name, estimate.Variation, test.variation)
+		}
+		if estimate.Unstable != test.unstable {
+			t.Errorf("%s: unstable %t, want %t", test.name, estimate.Unstable, test.unstable)
+		}
+		if len(test.values) == 1 && (estimate.MeanLow != 5 || estimate.MeanHigh != 5 || estimate.MedianLow != 5 || estimate.MedianHigh != 5) {
+			t.Errorf("%s: intervals [%f, %f] and [%f, %f], want [5, 5]", test.name, estimate.MeanLow, estimate.MeanHigh, estimate.MedianLow, estimate.MedianHigh)
+		}
+	}
+
+	if estimate := estimate(rand.New(rand.NewSource(1)), "empty", "ns", nil, testMaxVariationDefault); estimate.Mean != 0 || estimate.Unstable {
+		t.Errorf("empty: %+v, want a zero estimate", estimate)
+	}
+}
+
+func TestTrialsStopAtTheFirstError(t *testing.T) {
+	failure := errors.New("failed")
+	runs := 0
+	trials, err := NewDbTester(nil).Trials(context.Background(), 5, func(context.Context) (Result, error) {
+		runs++
+		histogram := NewHistogram()
+		histogram.Record(time.Duration(runs) * time.Millisecond)
+		if runs == 2 {
+			return newResult(histogram), failure
+		}
+		return newResult(histogram), nil
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("error %v, want %v", err, failure)
+	}
+	if runs != 2 || len(trials.Runs) != 2 {
+		t.Errorf("ran %d trials and kept %d, want 2", runs, len(trials.Runs))
+	}
+	if trials.Merged.Count != 2 {
+		t.Errorf("merged %d operations, want 2", trials.Merged.Count)
+	}
+	if len(trials.Estimates) != len(trialStatistics) {
+		t.Errorf("%d estimates, want %d", len(trials.Estimates), len(trialStatistics))
+	}
+}
@@ -0,0 +1,85 @@
+package test
+
+import (
+	"context"
+	"fmt"
+)
+
+// A test database that can overwrite existing test data.
+type Updater interface {
+	UpdateTestData(context.Context, TestData) error // Replaces the text and timestamp of existing test data.
+}
+
+// A test database that can delete test data.
+type Deleter interface {
+	DeleteTestData(context.Context, int64) error // Deletes the test data with the given key.
+}
+
+// Overwrites the written test data and returns the latency statistics. The
+// keys are picked with the tester's key distribution, in order by default. The
+// error wraps ErrUnsupported if the test database cannot update, and is
+// ErrNoKeys if nothing has been written yet.
+func (tester dbTester) TimeUpdates(ctx context.Context) (Result, error) {
+	updater, ok := tester.db.(Updater)
+	if !ok {
+		return newResult(NewHistogram()), fmt.Errorf("%T does not implement test.Updater: %w", tester.db, ErrUnsupported)
+	}
+	if len(tester.keys.snapshot()) == 0 {
+		return newResult(NewHistogram()), ErrNoKeys
+	}
+
+	distribution := tester.keyDistributionOr(SequentialKeys())
+	keys := tester.readKeys(newLockedRand(tester.seed), distribution)
+	result, err := tester.timePrepared(ctx, "updating", keys, func(key int64) operation {
+		data := tester.newTestData(key, "UpdatedText")
+		return func(ctx context.Context, _ int64) (outcome, error) {
+			return tester.update(ctx, updater, data)
+		}
+	})
+	result.Keys = tester.describeKeys(distribution)
+	return result, err
+}
+
+// Deletes the written test data in order and returns the latency statistics.
+// Each key is deleted once, so the run ends early if the tester's total or
+// duration outlasts the written keys. Deleted keys are no longer read or
+// updated by later phases. The error wraps ErrUnsupported if the test database
+// cannot delete, and is ErrNoKeys if nothing has been written yet.
+func (tester dbTester) TimeDeletes(ctx context.Context) (Result, error) {
+	deleter, ok := tester.db.(Deleter)
+	if !ok {
+		return newResult(NewHistogram()), fmt.Errorf("%T does not implement test.Deleter: %w", tester.db, ErrUnsupported)
+	}
+	written := tester.keys.snapshot()
+	if len(written) == 0 {
+		return newResult(NewHistogram()), ErrNoKeys
+	}
+
+	// The keys deleted by earlier rounds of a comparison are already gone from
+	// the snapshot, so the remaining keys start at the front again.
+	tester.offset = 0
+	keys := func(n int) (int64, bool) {
+		if n >= len(written) {
+			return 0, false
+		}
+		return written[n], true
+	}
+	return tester.timeOperations(ctx, "deleting", keys, func(ctx context.Context, key int64) (outcome, error) {
+		if err := deleter.DeleteTestData(ctx, key); err != nil {
+			return outcome{}, err
+		}
+		tester.keys.remove(key)
+		return outcome{}, nil
+	})
+}
+
+// Overwrites the test data for its key.
+func (tester dbTester) update(ctx context.Context, db Updater, data TestData) (outcome, error) {
+	tester.keys.start(data)
+	err := db.UpdateTestData(ctx, data)
+	tester.keys.finish(data, err == nil)
+	if err != nil {
+		return outcome{}, err
+	}
+	return outcome{bytes: len(data.Text)}, nil
+}
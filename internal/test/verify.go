@@ -0,0 +1,135 @@
+package test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The number of mismatches kept as examples in a verification report.
+const verificationExamples = 3
+
+// The outcome of comparing the test data that was read with what was written.
+type Verification struct {
+	Precision     time.Duration // The precision timestamps were compared at.
+	Checked       int           // The number of reads that were compared.
+	Matched       int           // Reads that returned exactly what was written, or matched at the precision.
+	Mismatched    int           // Reads that returned a different key, text or timestamp.
+	Missing       int           // Reads that found no test data for a written key.
+	PrecisionLoss int           // Matched reads whose timestamp only matched at the precision.
+	Unverified    int           // Reads of keys this tester did not write, so there was nothing to compare.
+	Examples      []string      // A few of the mismatches.
+}
+
+// Compares reads with the written test data and counts the differences.
+type verifier struct {
+	mutex        sync.Mutex
+	verification Verification
+}
+
+func newVerifier(precision time.Duration) *verifier {
+	return &verifier{verification: Verification{Precision: precision, Examples: make([]string, 0)}}
+}
+
+// Checks the outcome of a read against the test data it may have seen. There
+// can be more than one candidate, since an update may have been in flight
+// while the read was.
+func (v *verifier) check(key int64, data *TestData, err error, expected []TestData) {
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return
+	}
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	verification := &v.verification
+	if len(expected) == 0 {
+		verification.Unverified++
+		return
+	}
+	verification.Checked++
+	if data == nil {
+		verification.Missing++
+		return
+	}
+
+	match, lossy := false, true
+	for _, candidate := range expected {
+		if candidateMatch, candidateLossy := v.compare(*data, candidate); candidateMatch {
+			match = true
+			lossy = lossy && candidateLossy
+		}
+	}
+	if !match {
+		verification.Mismatched++
+		if len(verification.Examples) < verificationExamples {
+			verification.Examples = append(verification.Examples, fmt.Sprintf("key %d: wrote %s, read %s", key, expected[len(expected)-1], *data))
+		}
+		return
+	}
+	verification.Matched++
+	if lossy {
+		verification.PrecisionLoss++
+	}
+}
+
+// Whether the data matches the expected data, and whether the timestamps only
+// matched within the precision. Databases either truncate or round what they
+// store, so a timestamp read back up to the precision later or earlier than
+// it was written still matches.
+func (v *verifier) compare(data TestData, expected TestData) (bool, bool) {
+	if data.Key != expected.Key || data.Text != expected.Text {
+		return false, false
+	}
+	if data.Timestamp.Equal(expected.Timestamp) {
+		return true, false
+	}
+	difference := data.Timestamp.Sub(expected.Timestamp)
+	if difference < 0 {
+		difference = -difference
+	}
+	return difference < v.verification.Precision, true
+}
+
+func (v *verifier) result() *Verification {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	verification := v.verification
+	verification.Examples = append([]string{}, v.verification.Examples...)
+	return &verification
+}
+
+// Adds the counts of the other verification to this one.
+func (verification *Verification) merge(other *Verification) *Verification {
+	if verification == nil {
+		return other
+	}
+	if other == nil {
+		return verification
+	}
+	merged := *verification
+	merged.Checked += other.Checked
+	merged.Matched += other.Matched
+	merged.Mismatched += other.Mismatched
+	merged.Missing += other.Missing
+	merged.PrecisionLoss += other.PrecisionLoss
+	merged.Unverified += other.Unverified
+	merged.Examples = append(append([]string{}, verification.Examples...), other.Examples...)
+	if len(merged.Examples) > verificationExamples {
+		merged.Examples = merged.Examples[:verificationExamples]
+	}
+	return &merged
+}
+
+func (verification Verification) String() string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "verification: %d checked, %d matched, %d mismatched, %d missing, %d with timestamp precision loss (compared at %s)",
+		verification.Checked, verification.Matched, verification.Mismatched, verification.Missing, verification.PrecisionLoss, verification.Precision)
+	if verification.Unverified > 0 {
+		fmt.Fprintf(&builder, ", %d unverified", verification.Unverified)
+	}
+	for _, example := range verification.Examples {
+		fmt.Fprintf(&builder, "\n    %s", example)
+	}
+	return builder.String()
+}
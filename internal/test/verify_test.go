@@ -0,0 +1,34 @@
+package test
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVerifierPrecision(t *testing.T) {
+	written := TestData{Key: 1, Text: "text", Timestamp: time.Date(2024, 1, 2, 3, 4, 5, 600_000_000, time.UTC)}
+	tests := []struct {
+		name       string
+		read       time.Time
+		matched    int
+		mismatched int
+		lossy      int
+	}{
+		{"exact", written.Timestamp, 1, 0, 0},
+		{"truncated", written.Timestamp.Truncate(time.Second), 1, 0, 1},
+		{"rounded up", written.Timestamp.Round(time.Second), 1, 0, 1},
+		{"a second off", written.Timestamp.Add(time.Second), 0, 1, 0},
+		{"other zone", written.Timestamp.In(time.FixedZone("UTC+2", 2*60*60)), 1, 0, 0},
+	}
+	for _, test := range tests {
+		v := newVerifier(time.Second)
+		read := written
+		read.Timestamp = test.read
+		v.check(written.Key, &read, nil, []TestData{written})
+		result := v.result()
+		if result.Matched != test.matched || result.Mismatched != test.mismatched || result.PrecisionLoss != test.lossy {
+			t.Errorf("%s: matched %d, mismatched %d, precision loss %d, want %d, %d, %d", test.name,
+				result.Matched, result.Mismatched, result.PrecisionLoss, test.matched, test.mismatched, test.lossy)
+		}
+	}
+}
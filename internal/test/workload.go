@@ -0,0 +1,185 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+)
+
+// Returned when the test database does not support an operation a phase needs.
+var ErrUnsupported = errors.New("The test database does not support this operation.")
+
+// Returned when a phase needs existing test data but nothing has been written.
+var ErrNoKeys = errors.New("No test data has been written. Run TimeWrites first.")
+
+// The operation types of a mixed workload.
+const (
+	OpRead            = "read"
+	OpUpdate          = "update"
+	OpInsert          = "insert"
+	OpScan            = "scan"
+	OpReadModifyWrite = "read-modify-write"
+)
+
+// The mix of operations in a workload. The proportions are relative weights,
+// so {Read: 95, Update: 5} and {Read: 0.95, Update: 0.05} are the same mix.
+type Workload struct {
+	Name            string  // The name shown in reports.
+	Read            float64 // The proportion of point reads.
+	Update          float64 // The proportion of overwrites of existing test data.
+	Insert          float64 // The proportion of writes of new test data.
+	Scan            float64 // The proportion of short range scans.
+	ReadModifyWrite float64 // The proportion of reads followed by an update of the same key.
+
+	Keys KeyDistribution // How keys are picked, unless the tester has its own. Nil means uniform.
+}
+
+// The core workloads from the Yahoo! Cloud Serving Benchmark.
+var (
+	WorkloadA = Workload{Name: "YCSB A (update heavy)", Read: 0.5, Update: 0.5, Keys: ZipfianKeys(0.99)}
+	WorkloadB = Workload{Name: "YCSB B (read mostly)", Read: 0.95, Update: 0.05, Keys: ZipfianKeys(0.99)}
+	WorkloadC = Workload{Name: "YCSB C (read only)", Read: 1, Keys: ZipfianKeys(0.99)}
+	WorkloadD = Workload{Name: "YCSB D (read latest)", Read: 0.95, Insert: 0.05, Keys: LatestKeys(0.99)}
+	WorkloadE = Workload{Name: "YCSB E (short ranges)", Scan: 0.95, Insert: 0.05, Keys: ZipfianKeys(0.99)}
+	WorkloadF = Workload{Name: "YCSB F (read-modify-write)", Read: 0.5, ReadModifyWrite: 0.5, Keys: ZipfianKeys(0.99)}
+)
+
+// Returns the YCSB core workload with the given letter.
+func YCSBWorkload(name string) (Workload, bool) {
+	workload, ok := map[string]Workload{
+		"a": WorkloadA, "A": WorkloadA,
+		"b": WorkloadB, "B": WorkloadB,
+		"c": WorkloadC, "C": WorkloadC,
+		"d": WorkloadD, "D": WorkloadD,
+		"e": WorkloadE, "E": WorkloadE,
+		"f": WorkloadF, "F": WorkloadF,
+	}[name]
+	return workload, ok
+}
+
+// Picks an operation type according to the workload's proportions.
+func (workload Workload) pick(random *lockedRand) string {
+	weights := []struct {
+		kind   string
+		weight float64
+	}{
+		{OpRead, workload.Read},
+		{OpUpdate, workload.Update},
+		{OpInsert, workload.Insert},
+		{OpScan, workload.Scan},
+		{OpReadModifyWrite, workload.ReadModifyWrite},
+	}
+	var total float64
+	for _, weight := range weights {
+		total += weight.weight
+	}
+	choice := random.Float64() * total
+	for _, weight := range weights {
+		if choice < weight.weight {
+			return weight.kind
+		}
+		choice -= weight.weight
+	}
+	return OpRead
+}
+
+// Runs the mixed workload and returns the latency statistics, broken down by
+// operation type. The error is ErrNoKeys if nothing has been written yet.
+func (tester dbTester) TimeMixed(ctx context.Context, workload Workload) (Result, error) {
+	db := withContext(tester.db)
+	updater, canUpdate := tester.db.(Updater)
+	if (workload.Update > 0 || workload.ReadModifyWrite > 0) && !canUpdate {
+		return newResult(NewHistogram()), fmt.Errorf("%s needs updates but %T does not implement test.Updater: %w", workload.Name, tester.db, ErrUnsupported)
+	}
+	scanner, canScan := tester.db.(Scanner)
+	if workload.Scan > 0 && !canScan {
+		return newResult(NewHistogram()), fmt.Errorf("%s needs scans but %T does not implement test.Scanner: %w", workload.Name, tester.db, ErrUnsupported)
+	}
+	if len(tester.keys.snapshot()) == 0 {
+		return newResult(NewHistogram()), ErrNoKeys
+	}
+
+	distribution := workload.Keys
+	if distribution == nil {
+		distribution = UniformKeys()
+	}
+	distribution = tester.keyDistributionOr(distribution)
+	random := newLockedRand(tester.seed)
+	v := tester.newVerifier()
+	sequence := func(n int) (int64, bool) { return int64(n), true }
+	// The operation type, its key and the test data it writes are picked
+	// before the operation is timed.
+	result, err := tester.timePrepared(ctx, "running "+workload.Name, sequence, func(op int64) operation {
+		kind := workload.pick(random)
+		var timed operation
+		switch kind {
+		case OpUpdate:
+			data := tester.newTestData(tester.keys.pick(random, distribution, int(op)), "UpdatedText")
+			timed = func(ctx context.Context, _ int64) (outcome, error) {
+				return tester.update(ctx, updater, data)
+			}
+		case OpInsert:
+			data := tester.newTestData(tester.keys.claim(), "SampleText")
+			timed = func(ctx context.Context, _ int64) (outcome, error) {
+				return tester.write(ctx, db, data)
+			}
+		case OpScan:
+			key := tester.keys.pick(random, distribution, int(op))
+			timed = func(ctx context.Context, _ int64) (outcome, error) {
+				return tester.scan(ctx, scanner, key)
+			}
+		case OpReadModifyWrite:
+			data := tester.newTestData(tester.keys.pick(random, distribution, int(op)), "UpdatedText")
+			timed = func(ctx context.Context, _ int64) (outcome, error) {
+				out, err := tester.read(ctx, db, data.Key, v)
+				if err == nil {
+					var written outcome
+					written, err = tester.update(ctx, updater, data)
+					out.bytes += written.bytes
+				}
+				return out, err
+			}
+		default:
+			key := tester.keys.pick(random, distribution, int(op))
+			timed = func(ctx context.Context, _ int64) (outcome, error) {
+				return tester.read(ctx, db, key, v)
+			}
+		}
+		return func(ctx context.Context, op int64) (outcome, error) {
+			out, err := timed(ctx, op)
+			out.kind = kind
+			return out, err
+		}
+	})
+	result.Workload = workload.Name
+	result.Keys = tester.describeKeys(distribution)
+	if v != nil {
+		result.Verification = v.result()
+	}
+	return result, err
+}
+
+// A random number generator that is safe to share between goroutines.
+type lockedRand struct {
+	mutex  sync.Mutex
+	random *rand.Rand
+}
+
+func newLockedRand(seed int64) *lockedRand {
+	return &lockedRand{random: rand.New(rand.NewSource(seed))}
+}
+
+func (random *lockedRand) Float64() float64 {
+	random.mutex.Lock()
+	defer random.mutex.Unlock()
+	return random.random.Float64()
+}
+
+// Picks a key index from the distribution.
+func (random *lockedRand) index(keys KeyDistribution, op int, count int) int {
+	random.mutex.Lock()
+	defer random.mutex.Unlock()
+	return keys.Next(random.random, op, count)
+}
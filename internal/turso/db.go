@@ -1,36 +1,258 @@
 package turso
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
 
 	"github.com/timsexperiments/distributed-db-test/internal/test"
 )
 
 type Turso struct {
-	Db *sql.DB
+	Db    *sql.DB
+	runID string // Suffixes the table names so runs do not share tables. Empty for the unsuffixed tables.
+}
+
+// Suffixes the libSQL table names with the run ID, so runs sharing a Turso
+// database keep their rows apart.
+func (turso Turso) WithRunID(runID string) Turso {
+	turso.runID = runID
+	return turso
+}
+
+// The libSQL table holding this run's test data.
+func (turso Turso) testdata() string {
+	return test.TableName("testdata", turso.runID)
+}
+
+// The libSQL table holding this run's counters.
+func (turso Turso) counters() string {
+	return test.TableName("counters", turso.runID)
+}
+
+// Runs a query that touches no table, to check the database is reachable and
+// accepts the auth token.
+func (turso Turso) Preflight(ctx context.Context) error {
+	var one int
+	if err := turso.Db.QueryRowContext(ctx, "SELECT 1").Scan(&one); err != nil {
+		return fmt.Errorf("Unable to query Turso. Check TURSO_URL points at your database and its authToken is current: %w", httpStatus(err))
+	}
+	return nil
+}
+
+// Creates the run's tables from scratch, dropping whatever an earlier run with
+// the same ID left in the Turso database.
+func (turso Turso) Setup(ctx context.Context) error {
+	for _, statement := range []string{
+		fmt.Sprintf("DROP TABLE IF EXISTS %s", turso.testdata()),
+		fmt.Sprintf("CREATE TABLE %s (key INT PRIMARY KEY, text TEXT, timestamp DATETIME)", turso.testdata()),
+		fmt.Sprintf("DROP TABLE IF EXISTS %s", turso.counters()),
+		fmt.Sprintf("CREATE TABLE %s (key INT PRIMARY KEY, value BIGINT NOT NULL)", turso.counters()),
+	} {
+		if _, err := turso.Db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("%s: %w", statement, err)
+		}
+	}
+	return nil
+}
+
+// Drops both of the run's tables from the Turso database.
+func (turso Turso) Teardown(ctx context.Context) error {
+	for _, statement := range []string{
+		fmt.Sprintf("DROP TABLE IF EXISTS %s", turso.testdata()),
+		fmt.Sprintf("DROP TABLE IF EXISTS %s", turso.counters()),
+	} {
+		if _, err := turso.Db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("%s: %w", statement, err)
+		}
+	}
+	return nil
+}
+
+func (turso Turso) Close() error {
+	return turso.Db.Close()
 }
 
 func (turso Turso) ReadTestData(key int64) (*test.TestData, error) {
-	rows, err := turso.Db.Query("SELECT key, text, timestamp FROM testdata WHERE key = ?", key)
+	return turso.ReadTestDataContext(context.Background(), key)
+}
+
+func (turso Turso) ReadTestDataContext(ctx context.Context, key int64) (*test.TestData, error) {
+	rows, err := turso.Db.QueryContext(ctx, fmt.Sprintf("SELECT key, text, timestamp FROM %s WHERE key = ?", turso.testdata()), key)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "There was an error reading testdata [%d] from the database: %v\n", key, err.Error())
-		return nil, err
+		return nil, httpStatus(err)
 	}
 	defer rows.Close()
 	if rows.Next() {
 		result := &test.TestData{}
-		rows.Scan(&result.Key, &result.Text, &result.Timestamp)
+		if err := rows.Scan(&result.Key, &result.Text, &result.Timestamp); err != nil {
+			return nil, err
+		}
 		return result, nil
 	}
-	return nil, nil
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return nil, test.ErrNotFound
+}
+
+func (turso Turso) ScanTestData(ctx context.Context, from int64, to int64, limit int) ([]test.TestData, error) {
+	rows, err := turso.Db.QueryContext(ctx, fmt.Sprintf("SELECT key, text, timestamp FROM %s WHERE key BETWEEN ? AND ? ORDER BY key LIMIT ?", turso.testdata()), from, to, limit)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "There was an error scanning testdata [%d-%d] from the database: %v\n", from, to, err.Error())
+		return nil, httpStatus(err)
+	}
+	defer rows.Close()
+	page := make([]test.TestData, 0, limit)
+	for rows.Next() {
+		var data test.TestData
+		if err := rows.Scan(&data.Key, &data.Text, &data.Timestamp); err != nil {
+			return nil, err
+		}
+		page = append(page, data)
+	}
+	return page, rows.Err()
 }
 
 func (turso Turso) WriteTestData(data test.TestData) error {
-	_, err := turso.Db.Exec("INSERT INTO testdata(key, text, timestamp) VALUES (?, ?, ?)", data.Key, data.Text, data.Timestamp)
+	return turso.WriteTestDataContext(context.Background(), data)
+}
+
+func (turso Turso) WriteTestDataContext(ctx context.Context, data test.TestData) error {
+	_, err := turso.Db.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s(key, text, timestamp) VALUES (?, ?, ?)", turso.testdata()), data.Key, data.Text, data.Timestamp)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "There was an error writing testdata [%v] to the database: %v\n", data, err.Error())
 	}
-	return err
+	return httpStatus(err)
+}
+
+func (turso Turso) WriteTestDataBatch(ctx context.Context, batch []test.TestData) error {
+	// SQLite rejects an INSERT whose VALUES list is empty.
+	if len(batch) == 0 {
+		return nil
+	}
+	values := make([]string, len(batch))
+	args := make([]any, 0, len(batch)*3)
+	for i, data := range batch {
+		values[i] = "(?, ?, ?)"
+		args = append(args, data.Key, data.Text, data.Timestamp)
+	}
+	_, err := turso.Db.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s(key, text, timestamp) VALUES %s", turso.testdata(), strings.Join(values, ", ")), args...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "There was an error writing a batch of %d testdata to the database: %v\n", len(batch), err.Error())
+	}
+	return httpStatus(err)
+}
+
+func (turso Turso) ReadTestDataBatch(ctx context.Context, keys []int64) ([]test.TestData, error) {
+	// There is nothing to look up, so skip the round trip to Turso.
+	if len(keys) == 0 {
+		return []test.TestData{}, nil
+	}
+	placeholders := make([]string, len(keys))
+	args := make([]any, len(keys))
+	for i, key := range keys {
+		placeholders[i] = "?"
+		args[i] = key
+	}
+	rows, err := turso.Db.QueryContext(ctx, fmt.Sprintf("SELECT key, text, timestamp FROM %s WHERE key IN (%s)", turso.testdata(), strings.Join(placeholders, ", ")), args...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "There was an error reading a batch of %d testdata from the database: %v\n", len(keys), err.Error())
+		return nil, httpStatus(err)
+	}
+	defer rows.Close()
+	batch := make([]test.TestData, 0, len(keys))
+	for rows.Next() {
+		var data test.TestData
+		if err := rows.Scan(&data.Key, &data.Text, &data.Timestamp); err != nil {
+			return nil, err
+		}
+		batch = append(batch, data)
+	}
+	return batch, rows.Err()
+}
+
+func (turso Turso) UpdateTestData(ctx context.Context, data test.TestData) error {
+	_, err := turso.Db.ExecContext(ctx, fmt.Sprintf("UPDATE %s SET text = ?, timestamp = ? WHERE key = ?", turso.testdata()), data.Text, data.Timestamp, data.Key)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "There was an error updating testdata [%v] in the database: %v\n", data, err.Error())
+	}
+	return httpStatus(err)
+}
+
+func (turso Turso) DeleteTestData(ctx context.Context, key int64) error {
+	_, err := turso.Db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE key = ?", turso.testdata()), key)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "There was an error deleting testdata [%d] from the database: %v\n", key, err.Error())
+	}
+	return httpStatus(err)
+}
+
+func (turso Turso) ResetCounter(ctx context.Context, key int64) error {
+	_, err := turso.Db.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s(key, value) VALUES (?, 0) ON CONFLICT(key) DO UPDATE SET value = 0", turso.counters()), key)
+	return httpStatus(err)
+}
+
+// Reads the counter and writes it back one higher in a transaction. SQLite
+// only lets one transaction write at a time, so a transaction that cannot take
+// the write lock is aborted and returned as a conflict.
+func (turso Turso) IncrementCounter(ctx context.Context, key int64) error {
+	tx, err := turso.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return conflict(err)
+	}
+	defer tx.Rollback()
+	var value int64
+	if err := tx.QueryRowContext(ctx, fmt.Sprintf("SELECT value FROM %s WHERE key = ?", turso.counters()), key).Scan(&value); err != nil {
+		return conflict(err)
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("UPDATE %s SET value = ? WHERE key = ?", turso.counters()), value+1, key); err != nil {
+		return conflict(err)
+	}
+	return conflict(tx.Commit())
+}
+
+func (turso Turso) ReadCounter(ctx context.Context, key int64) (int64, error) {
+	var value int64
+	err := turso.Db.QueryRowContext(ctx, fmt.Sprintf("SELECT value FROM %s WHERE key = ?", turso.counters()), key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, test.ErrNotFound
+	}
+	return value, httpStatus(err)
+}
+
+// Marks errors from a busy or locked database as transaction conflicts.
+func conflict(err error) error {
+	if err == nil {
+		return nil
+	}
+	if message := err.Error(); strings.Contains(message, "SQLITE_BUSY") || strings.Contains(message, "database is locked") {
+		return fmt.Errorf("%w: %s", test.ErrConflict, message)
+	}
+	return httpStatus(err)
+}
+
+// The shape of the errors libSQL returns for unsuccessful HTTP responses,
+// which it only reports as text.
+var httpStatusPattern = regexp.MustCompile(`error code (\d{3}): `)
+
+// Turns errors libSQL returns for unsuccessful HTTP responses into
+// test.HTTPStatusError, so they are classed by their status code.
+func httpStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	message := err.Error()
+	match := httpStatusPattern.FindStringSubmatchIndex(message)
+	if match == nil {
+		return err
+	}
+	statusCode, _ := strconv.Atoi(message[match[2]:match[3]])
+	return test.HTTPStatusError{StatusCode: statusCode, Body: message[match[1]:]}
 }
@@ -0,0 +1,35 @@
+package turso
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/timsexperiments/distributed-db-test/internal/test"
+)
+
+func TestHTTPStatusErrorsAreClassedByStatus(t *testing.T) {
+	tests := []struct {
+		name  string
+		err   error
+		class string
+	}{
+		{"rate limited", fmt.Errorf("error code %d: %s", 429, "too many requests"), "http 429"},
+		{"server error", fmt.Errorf("error code %d: %s", 502, "bad gateway"), "http 502"},
+		{"statement error", errors.New("error code SQL_PARSE_ERROR: near \"SELEC\": syntax error"), test.ErrorClassDriver},
+		{"busy", errors.New("error code 503: SQLITE_BUSY: database is locked"), test.ErrorClassConflict},
+	}
+	for _, tt := range tests {
+		if class := test.ClassifyError(conflict(tt.err)); class != tt.class {
+			t.Errorf("%s: classed as %q, want %q", tt.name, class, tt.class)
+		}
+	}
+
+	var statusErr test.HTTPStatusError
+	if !errors.As(httpStatus(fmt.Errorf("error code %d: %s", 429, "slow down")), &statusErr) || statusErr.Body != "slow down" {
+		t.Errorf("body %q, want %q", statusErr.Body, "slow down")
+	}
+	if httpStatus(nil) != nil {
+		t.Error("a nil error was turned into an HTTP status error")
+	}
+}
@@ -0,0 +1,32 @@
+package turso
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "github.com/libsql/libsql-client-go/libsql"
+	"github.com/timsexperiments/distributed-db-test/internal/test"
+)
+
+func init() {
+	test.Register(test.Adapter{
+		Name:        "turso",
+		Description: "Turso (libSQL) over its SQL driver",
+		Env:         []string{"TURSO_URL"},
+		Open: func(_ context.Context, runID string) (test.TestDatabase, error) {
+			env, err := test.RequireEnv("TURSO_URL")
+			if err != nil {
+				return nil, err
+			}
+			db, err := sql.Open("libsql", env[0])
+			if err != nil {
+				return nil, fmt.Errorf("TURSO_URL is not a valid libSQL URL. It should look like libsql://<database>.turso.io?authToken=<token>: %w", err)
+			}
+			return Turso{Db: db}.WithRunID(runID), nil
+		},
+		Pause:     10 * time.Second, // I keep getting rate limited on Turso. Pause prevents this.
+		OpTimeout: 30 * time.Second,
+	})
+}
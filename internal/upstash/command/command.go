@@ -40,6 +40,18 @@ func Delete(keys ...string) Command {
 	return Command{action: "DEL", args: keys}
 }
 
+func ZAdd(key string, score string, member string) Command {
+	return Command{action: "ZADD", args: []string{key, score, member}}
+}
+
+func ZRem(key string, members ...string) Command {
+	return Command{action: "ZREM", args: append([]string{key}, members...)}
+}
+
+func ZRangeByScore(key string, min string, max string, offset int, count int) Command {
+	return Command{action: "ZRANGEBYSCORE", args: []string{key, min, max, "LIMIT", fmt.Sprint(offset), fmt.Sprint(count)}}
+}
+
 func Custom(action string, args ...string) Command {
 	return Command{action: action, args: args}
 }
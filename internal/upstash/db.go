@@ -1,6 +1,7 @@
 package upstash
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -18,15 +19,43 @@ import (
 type Upstash struct {
 	url   string
 	token string
+	runID string // Prefixes every key so runs do not share data. Empty for unprefixed keys.
 }
 
 func NewUpstashClient(url, token string) Upstash {
 	return Upstash{url: url, token: token}
 }
 
+// Prefixes every key with the run ID, so runs against the same database never
+// touch each other's test data.
+func (db Upstash) WithRunID(runID string) Upstash {
+	db.runID = runID
+	return db
+}
+
+// The key of the hash that holds the test data.
+func (db Upstash) dataKey(key string) string {
+	return fmt.Sprintf("%stestdata:%s", test.KeyPrefix(db.runID), key)
+}
+
+// The key of the sorted set of written test data keys, scored by key, used to
+// scan key ranges.
+func (db Upstash) indexKey() string {
+	return test.KeyPrefix(db.runID) + "testdata:index"
+}
+
+// The key of the counter.
+func (db Upstash) counterKey(key int64) string {
+	return fmt.Sprintf("%scounter:%d", test.KeyPrefix(db.runID), key)
+}
+
 func (db Upstash) ReadTestData(key int64) (*test.TestData, error) {
-	lookupKey := fmt.Sprintf("testdata:%d", key)
-	res, err := db.request(command.HGet(lookupKey, "key"), command.HGet(lookupKey, "text"), command.HGet(lookupKey, "timestamp"))
+	return db.ReadTestDataContext(context.Background(), key)
+}
+
+func (db Upstash) ReadTestDataContext(ctx context.Context, key int64) (*test.TestData, error) {
+	lookupKey := db.dataKey(fmt.Sprint(key))
+	res, err := db.request(ctx, command.HGet(lookupKey, "key"), command.HGet(lookupKey, "text"), command.HGet(lookupKey, "timestamp"))
 	if err != nil {
 		return nil, err
 	}
@@ -38,6 +67,14 @@ func (db Upstash) ReadTestData(key int64) (*test.TestData, error) {
 	if (len(responses)) != 3 {
 		return nil, fmt.Errorf("There should have been 3 results. Found [%d].", len(responses))
 	}
+	for _, response := range responses {
+		if response.Error != "" {
+			return nil, fmt.Errorf("Unable to read %s: %s", lookupKey, response.Error)
+		}
+	}
+	if responses[0].Result == "" {
+		return nil, test.ErrNotFound
+	}
 	foundKey, err := strconv.ParseInt(responses[0].Result, 10, 64)
 	if err != nil {
 		return nil, err
@@ -51,31 +88,306 @@ func (db Upstash) ReadTestData(key int64) (*test.TestData, error) {
 }
 
 func (db Upstash) WriteTestData(data test.TestData) error {
+	return db.WriteTestDataContext(context.Background(), data)
+}
+
+func (db Upstash) WriteTestDataContext(ctx context.Context, data test.TestData) error {
+	return db.send(ctx, fmt.Sprintf("write testdata %d", data.Key), db.writeCommands(data)...)
+}
+
+// Writes the whole batch in one pipeline.
+func (db Upstash) WriteTestDataBatch(ctx context.Context, batch []test.TestData) error {
+	// There is nothing to send, and the pipeline API rejects empty pipelines.
+	if len(batch) == 0 {
+		return nil
+	}
+	commands := make([]command.Command, 0, len(batch)*2)
+	for _, data := range batch {
+		commands = append(commands, db.writeCommands(data)...)
+	}
+	return db.send(ctx, fmt.Sprintf("write a batch of %d testdata", len(batch)), commands...)
+}
+
+// Reads the whole batch in one pipeline. Keys that are not found are left out.
+func (db Upstash) ReadTestDataBatch(ctx context.Context, keys []int64) ([]test.TestData, error) {
+	lookupKeys := make([]string, len(keys))
+	for i, key := range keys {
+		lookupKeys[i] = fmt.Sprint(key)
+	}
+	return db.readHashes(ctx, lookupKeys)
+}
+
+// The commands that store the test data as a hash and add its key to the index.
+func (db Upstash) writeCommands(data test.TestData) []command.Command {
 	dataMap := make(map[string]string)
 	dataMap["key"] = fmt.Sprint(data.Key)
 	dataMap["text"] = data.Text
 	dataMap["timestamp"] = fmt.Sprint(data.Timestamp.UnixNano())
-	command := command.HSet(dataMap, fmt.Sprintf("testdata:%d", data.Key))
-	_, err := db.request(command)
+	key := fmt.Sprint(data.Key)
+	return []command.Command{command.HSet(dataMap, db.dataKey(key)), command.ZAdd(db.indexKey(), key, key)}
+}
+
+// Replaces the text and timestamp of the test data hash if it exists, like the
+// SQL adapters' UPDATE. The test data is a hash, which SET XX would replace
+// with a string, so the check and the HSET run together in a script.
+const updateScript = `if redis.call("EXISTS", KEYS[1]) == 0 then return false end
+return redis.call("HSET", KEYS[1], "text", ARGV[1], "timestamp", ARGV[2])`
+
+// Overwrites existing test data. Test data that does not exist is not created
+// and the error is test.ErrNotFound.
+func (db Upstash) UpdateTestData(ctx context.Context, data test.TestData) error {
+	lookupKey := db.dataKey(fmt.Sprint(data.Key))
+	res, err := db.request(ctx, command.Custom("EVAL", updateScript, "1", lookupKey, data.Text, fmt.Sprint(data.Timestamp.UnixNano())))
 	if err != nil {
 		return err
 	}
+	var responses []struct {
+		Result *int64 `json:"result"`
+		Error  string `json:"error"`
+	}
+	if err := json.Unmarshal(res, &responses); err != nil {
+		return err
+	}
+	if len(responses) != 1 {
+		return fmt.Errorf("There should have been 1 result. Found [%d].", len(responses))
+	}
+	if responses[0].Error != "" {
+		return fmt.Errorf("Unable to update %s: %s", lookupKey, responses[0].Error)
+	}
+	if responses[0].Result == nil {
+		return test.ErrNotFound
+	}
 	return nil
 }
 
-func (db Upstash) Clean() error {
-	_, err := db.request(command.Custom("FLUSHALL"))
+func (db Upstash) DeleteTestData(ctx context.Context, key int64) error {
+	return db.send(ctx, fmt.Sprintf("delete testdata %d", key), command.Delete(db.dataKey(fmt.Sprint(key))), command.ZRem(db.indexKey(), fmt.Sprint(key)))
+}
+
+// Pages through the sorted-set index of keys, then fetches the hashes of the
+// keys in the page in one pipeline.
+func (db Upstash) ScanTestData(ctx context.Context, from int64, to int64, limit int) ([]test.TestData, error) {
+	res, err := db.request(ctx, command.ZRangeByScore(db.indexKey(), fmt.Sprint(from), fmt.Sprint(to), 0, limit))
+	if err != nil {
+		return nil, err
+	}
+	var ranges []ListResponse
+	if err := json.Unmarshal(res, &ranges); err != nil {
+		return nil, err
+	}
+	if len(ranges) != 1 {
+		return nil, fmt.Errorf("There should have been 1 result. Found [%d].", len(ranges))
+	}
+	if ranges[0].Error != "" {
+		return nil, fmt.Errorf("Unable to scan %s: %s", db.indexKey(), ranges[0].Error)
+	}
+	return db.readHashes(ctx, ranges[0].Result)
+}
+
+// Fetches the test data hashes of the keys in one pipeline. Keys that are not
+// found are left out.
+func (db Upstash) readHashes(ctx context.Context, keys []string) ([]test.TestData, error) {
+	if len(keys) == 0 {
+		return make([]test.TestData, 0), nil
+	}
+	commands := make([]command.Command, len(keys))
+	for i, key := range keys {
+		commands[i] = command.HGetAll(db.dataKey(key))
+	}
+	res, err := db.request(ctx, commands...)
 	if err != nil {
+		return nil, err
+	}
+	var hashes []ListResponse
+	if err := json.Unmarshal(res, &hashes); err != nil {
+		return nil, err
+	}
+	found := make([]test.TestData, 0, len(hashes))
+	for i, hash := range hashes {
+		if hash.Error != "" {
+			return nil, fmt.Errorf("Unable to read %s: %s", db.dataKey(keys[i]), hash.Error)
+		}
+		if len(hash.Result) == 0 {
+			continue
+		}
+		data, err := hashToTestData(hash.Result)
+		if err != nil {
+			return nil, err
+		}
+		found = append(found, data)
+	}
+	return found, nil
+}
+
+// Converts the field and value pairs of a test data hash to test data.
+func hashToTestData(fields []string) (test.TestData, error) {
+	values := make(map[string]string)
+	for i := 0; i+1 < len(fields); i += 2 {
+		values[fields[i]] = fields[i+1]
+	}
+	key, err := strconv.ParseInt(values["key"], 10, 64)
+	if err != nil {
+		return test.TestData{}, err
+	}
+	timestamp, err := strconv.ParseInt(values["timestamp"], 10, 64)
+	if err != nil {
+		return test.TestData{}, err
+	}
+	return test.TestData{Key: key, Text: values["text"], Timestamp: time.Unix(0, timestamp)}, nil
+}
+
+func (db Upstash) ResetCounter(ctx context.Context, key int64) error {
+	return db.send(ctx, "reset "+db.counterKey(key), command.Set("0", db.counterKey(key)))
+}
+
+// Increments the counter with INCR, which Redis runs atomically, so it never
+// conflicts.
+func (db Upstash) IncrementCounter(ctx context.Context, key int64) error {
+	res, err := db.request(ctx, command.Custom("INCR", db.counterKey(key)))
+	if err != nil {
+		return err
+	}
+	var responses []struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(res, &responses); err != nil {
 		return err
 	}
+	if len(responses) != 1 {
+		return fmt.Errorf("There should have been 1 result. Found [%d].", len(responses))
+	}
+	if responses[0].Error != "" {
+		return fmt.Errorf("Unable to increment %s: %s", db.counterKey(key), responses[0].Error)
+	}
+	return nil
+}
+
+func (db Upstash) ReadCounter(ctx context.Context, key int64) (int64, error) {
+	res, err := db.request(ctx, command.Get(db.counterKey(key)))
+	if err != nil {
+		return 0, err
+	}
+	var responses []Response
+	if err := json.Unmarshal(res, &responses); err != nil {
+		return 0, err
+	}
+	if len(responses) != 1 {
+		return 0, fmt.Errorf("There should have been 1 result. Found [%d].", len(responses))
+	}
+	if responses[0].Error != "" {
+		return 0, fmt.Errorf("Unable to read %s: %s", db.counterKey(key), responses[0].Error)
+	}
+	if responses[0].Result == "" {
+		return 0, test.ErrNotFound
+	}
+	return strconv.ParseInt(responses[0].Result, 10, 64)
+}
+
+// Deletes the run's test data and counters.
+func (db Upstash) Clean() error {
+	return db.clean(context.Background())
+}
+
+// Sends a PING, to check the REST API is reachable and accepts the token.
+func (db Upstash) Preflight(ctx context.Context) error {
+	_, err := db.request(ctx, command.Custom("PING"))
+	switch {
+	case test.IsAuthError(err):
+		return fmt.Errorf("Upstash rejected UPSTASH_REDIS_TOKEN. It should be the REST token of the database at UPSTASH_REDIS_URL: %w", err)
+	case err != nil:
+		return fmt.Errorf("Unable to reach Upstash. Check UPSTASH_REDIS_URL is the REST URL of your database: %w", err)
+	}
+	return nil
+}
+
+// Deletes test data and counters an earlier run with the same ID left.
+func (db Upstash) Setup(ctx context.Context) error {
+	return db.clean(ctx)
+}
+
+// Deletes the run's test data and counters.
+func (db Upstash) Teardown(ctx context.Context) error {
+	return db.clean(ctx)
+}
+
+// Deletes every key of the run, one page of SCAN results at a time. Only the
+// run's keys are touched, so other runs and other data in the database are
+// left alone.
+func (db Upstash) clean(ctx context.Context) error {
+	prefix := test.KeyPrefix(db.runID)
+	for _, pattern := range []string{prefix + "testdata:*", prefix + "counter:*"} {
+		cursor := "0"
+		for {
+			res, err := db.request(ctx, command.Custom("SCAN", cursor, "MATCH", pattern, "COUNT", "1000"))
+			if err != nil {
+				return err
+			}
+			var responses []struct {
+				Result []json.RawMessage `json:"result"`
+				Error  string            `json:"error"`
+			}
+			if err := json.Unmarshal(res, &responses); err != nil {
+				return err
+			}
+			if len(responses) != 1 || len(responses[0].Result) != 2 {
+				if len(responses) == 1 && responses[0].Error != "" {
+					return fmt.Errorf("Unable to scan %s: %s", pattern, responses[0].Error)
+				}
+				return fmt.Errorf("Unexpected SCAN response: %s", res)
+			}
+			var keys []string
+			if err := json.Unmarshal(responses[0].Result[0], &cursor); err != nil {
+				return err
+			}
+			if err := json.Unmarshal(responses[0].Result[1], &keys); err != nil {
+				return err
+			}
+			if len(keys) > 0 {
+				if _, err := db.request(ctx, command.Delete(keys...)); err != nil {
+					return err
+				}
+			}
+			if cursor == "0" {
+				break
+			}
+		}
+	}
 	return nil
 }
 
 type Response struct {
 	Result string `json:"result"`
+	Error  string `json:"error"`
 }
 
-func (db Upstash) request(commands ...command.Command) ([]byte, error) {
+type ListResponse struct {
+	Result []string `json:"result"`
+	Error  string   `json:"error"`
+}
+
+// Sends the commands and returns an error if any of them failed. The pipeline
+// answers 200 even when a command fails, so the error is read from the body.
+// The results are not read, for commands that only return counts.
+func (db Upstash) send(ctx context.Context, action string, commands ...command.Command) error {
+	res, err := db.request(ctx, commands...)
+	if err != nil {
+		return err
+	}
+	var responses []struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(res, &responses); err != nil {
+		return err
+	}
+	for _, response := range responses {
+		if response.Error != "" {
+			return fmt.Errorf("Unable to %s: %s", action, response.Error)
+		}
+	}
+	return nil
+}
+
+func (db Upstash) request(ctx context.Context, commands ...command.Command) ([]byte, error) {
 	requestUrl, err := url.JoinPath(db.url, "pipeline")
 	if err != nil {
 		return nil, err
@@ -92,7 +404,7 @@ func (db Upstash) request(commands ...command.Command) ([]byte, error) {
 	}
 	payload := strings.NewReader(fmt.Sprintf("[%s]", strings.Join(commandsList, ",")))
 
-	req, err := http.NewRequest("POST", requestUrl, payload)
+	req, err := http.NewRequestWithContext(ctx, "POST", requestUrl, payload)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
 		return nil, err
@@ -113,6 +425,9 @@ func (db Upstash) request(commands ...command.Command) ([]byte, error) {
 		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
 		return nil, err
 	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, test.HTTPStatusError{StatusCode: res.StatusCode, Body: string(body)}
+	}
 	// fmt.Printf("Response: %s\n", string(body))
 	return body, nil
 }
@@ -0,0 +1,58 @@
+package upstash
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/timsexperiments/distributed-db-test/internal/test"
+)
+
+// Starts a server that answers every pipeline with 200 and the given body.
+func newPipelineServer(t *testing.T, body string) Upstash {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return NewUpstashClient(server.URL, "token")
+}
+
+func TestFailedCommandsAreErrors(t *testing.T) {
+	db := newPipelineServer(t, `[{"result":1},{"error":"WRONGTYPE Operation against a key holding the wrong kind of value"}]`)
+	ctx := context.Background()
+	data := test.TestData{Key: 1, Text: "text", Timestamp: time.Now()}
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"write", db.WriteTestDataContext(ctx, data)},
+		{"batch write", db.WriteTestDataBatch(ctx, []test.TestData{data})},
+		{"delete", db.DeleteTestData(ctx, 1)},
+		{"reset counter", db.ResetCounter(ctx, 1)},
+	}
+	for _, test := range tests {
+		if test.err == nil {
+			t.Errorf("%s: a failed command was reported as a success", test.name)
+		}
+	}
+}
+
+func TestSucceededCommandsAreNotErrors(t *testing.T) {
+	db := newPipelineServer(t, `[{"result":1},{"result":1}]`)
+	if err := db.DeleteTestData(context.Background(), 1); err != nil {
+		t.Errorf("delete: %v", err)
+	}
+}
+
+func TestUpdateOfMissingTestDataIsNotFound(t *testing.T) {
+	data := test.TestData{Key: 1, Text: "text", Timestamp: time.Now()}
+	if err := newPipelineServer(t, `[{"result":null}]`).UpdateTestData(context.Background(), data); !errors.Is(err, test.ErrNotFound) {
+		t.Errorf("missing: error %v, want %v", err, test.ErrNotFound)
+	}
+	if err := newPipelineServer(t, `[{"result":0}]`).UpdateTestData(context.Background(), data); err != nil {
+		t.Errorf("existing: %v", err)
+	}
+}
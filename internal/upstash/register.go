@@ -0,0 +1,30 @@
+package upstash
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/timsexperiments/distributed-db-test/internal/test"
+)
+
+func init() {
+	test.Register(test.Adapter{
+		Name:        "upstash",
+		Description: "Upstash Redis over its REST pipeline API",
+		Env:         []string{"UPSTASH_REDIS_URL", "UPSTASH_REDIS_TOKEN"},
+		Open: func(_ context.Context, runID string) (test.TestDatabase, error) {
+			env, err := test.RequireEnv("UPSTASH_REDIS_URL", "UPSTASH_REDIS_TOKEN")
+			if err != nil {
+				return nil, err
+			}
+			if _, err := url.ParseRequestURI(env[0]); err != nil {
+				return nil, fmt.Errorf("UPSTASH_REDIS_URL is not a valid URL. It should be the REST URL of your database: %w", err)
+			}
+			return NewUpstashClient(env[0], env[1]).WithRunID(runID), nil
+		},
+		Warmup:    100, // The first requests pay for DNS, TCP and TLS setup.
+		OpTimeout: 30 * time.Second,
+	})
+}